@@ -3,11 +3,16 @@ module github.com/nick3/restreamer_monitor_go
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/go-resty/resty/v2 v2.16.2
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/gorilla/websocket v1.5.3
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/cobra v1.8.1
 	github.com/stretchr/testify v1.10.0
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/sync v0.8.0
+	golang.org/x/time v0.6.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
 
@@ -0,0 +1,149 @@
+// Package history persists live sessions in an embedded bbolt database
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// sessionsBucket holds one nested bucket per room, keyed by RoomKey. Sessions
+// are keyed by start time and ID, so a bucket iterates in start order.
+var sessionsBucket = []byte("sessions")
+
+// openTimeout bounds the wait for the database lock held by another process
+const openTimeout = 5 * time.Second
+
+// Store is a session history database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RoomKey returns the key that groups a room's sessions
+func RoomKey(platform, roomID string) string {
+	return fmt.Sprintf("%s:%s", platform, roomID)
+}
+
+// Save inserts a new session, assigning its ID, or updates a saved one.
+// A session's room and start time cannot change once it is saved.
+func (s *Store) Save(session *models.Session) error {
+	if session.StartTime.IsZero() {
+		return fmt.Errorf("session of room %s has no start time", RoomKey(session.Platform, session.RoomID))
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		room, err := sessions.CreateBucketIfNotExists([]byte(RoomKey(session.Platform, session.RoomID)))
+		if err != nil {
+			return fmt.Errorf("failed to create room bucket: %w", err)
+		}
+
+		if session.ID == 0 {
+			id, err := sessions.NextSequence()
+			if err != nil {
+				return fmt.Errorf("failed to assign session ID: %w", err)
+			}
+			session.ID = id
+		}
+
+		data, err := json.Marshal(session)
+		if err != nil {
+			return fmt.Errorf("failed to encode session: %w", err)
+		}
+		return room.Put(sessionKey(session.StartTime, session.ID), data)
+	})
+}
+
+// Sessions returns the sessions of a room that overlap the range from to
+// to, oldest first. A zero from or to leaves that end of the range open;
+// open sessions overlap every range after their start.
+func (s *Store) Sessions(platform, roomID string, from, to time.Time) ([]models.Session, error) {
+	var result []models.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		room := tx.Bucket(sessionsBucket).Bucket([]byte(RoomKey(platform, roomID)))
+		if room == nil {
+			return nil
+		}
+
+		c := room.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			// Keys are in start order, so nothing after to can overlap
+			if !to.IsZero() && !sessionStart(k).Before(to) {
+				break
+			}
+
+			var session models.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return fmt.Errorf("failed to decode session %x: %w", k, err)
+			}
+			if !from.IsZero() && !session.IsOpen() && session.EndTime.Before(from) {
+				continue
+			}
+			result = append(result, session)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// OpenSessions returns the sessions of all rooms that have not ended, such
+// as those left open when the process stopped during a live session
+func (s *Store) OpenSessions() ([]models.Session, error) {
+	var result []models.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEachBucket(func(name []byte) error {
+			return tx.Bucket(sessionsBucket).Bucket(name).ForEach(func(k, v []byte) error {
+				var session models.Session
+				if err := json.Unmarshal(v, &session); err != nil {
+					return fmt.Errorf("failed to decode session %x: %w", k, err)
+				}
+				if session.IsOpen() {
+					result = append(result, session)
+				}
+				return nil
+			})
+		})
+	})
+	return result, err
+}
+
+// sessionKey orders sessions by start time, breaking ties by ID
+func sessionKey(start time.Time, id uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(start.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], id)
+	return key
+}
+
+// sessionStart returns the start time encoded in a session key
+func sessionStart(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+}
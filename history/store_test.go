@@ -0,0 +1,104 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+func TestStore_SaveAndQuery(t *testing.T) {
+	store, _ := openTestStore(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	morning := &models.Session{Platform: "bilibili", RoomID: "76", Title: "Morning", StartTime: day.Add(8 * time.Hour), EndTime: day.Add(10 * time.Hour)}
+	evening := &models.Session{Platform: "bilibili", RoomID: "76", Title: "Evening", StartTime: day.Add(20 * time.Hour)}
+	other := &models.Session{Platform: "douyu", RoomID: "76", StartTime: day.Add(9 * time.Hour), EndTime: day.Add(11 * time.Hour)}
+	// Saved out of order, the query still returns them by start time
+	for _, session := range []*models.Session{evening, morning, other} {
+		require.NoError(t, store.Save(session))
+	}
+	assert.NotZero(t, morning.ID)
+	assert.NotEqual(t, morning.ID, evening.ID)
+
+	titles := func(sessions []models.Session) []string {
+		var result []string
+		for _, session := range sessions {
+			result = append(result, session.Title)
+		}
+		return result
+	}
+
+	all, err := store.Sessions("bilibili", "76", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Morning", "Evening"}, titles(all))
+	assert.True(t, all[0].EndTime.Equal(morning.EndTime))
+
+	// Sessions overlapping the range, including one that started before it
+	sessions, err := store.Sessions("bilibili", "76", day.Add(9*time.Hour), day.Add(12*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Morning"}, titles(sessions))
+
+	// Open sessions overlap every range after their start
+	sessions, err = store.Sessions("bilibili", "76", day.Add(48*time.Hour), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Evening"}, titles(sessions))
+
+	sessions, err = store.Sessions("bilibili", "76", time.Time{}, day.Add(8*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	sessions, err = store.Sessions("huya", "76", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestStore_UpdateAndReopen(t *testing.T) {
+	store, path := openTestStore(t)
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	session := &models.Session{Platform: "bilibili", RoomID: "76", StartTime: start}
+	require.NoError(t, store.Save(session))
+	id := session.ID
+
+	open, err := store.OpenSessions()
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	assert.Equal(t, id, open[0].ID)
+
+	// Updates replace the saved session rather than adding one
+	session.PeakViewers = 500
+	session.EndTime = start.Add(time.Hour)
+	require.NoError(t, store.Save(session))
+	assert.Equal(t, id, session.ID)
+
+	require.NoError(t, store.Close())
+	store, err = Open(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	sessions, err := store.Sessions("bilibili", "76", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 500, sessions[0].PeakViewers)
+	assert.Equal(t, time.Hour, sessions[0].Duration())
+
+	open, err = store.OpenSessions()
+	require.NoError(t, err)
+	assert.Empty(t, open)
+
+	assert.Error(t, store.Save(&models.Session{Platform: "bilibili", RoomID: "76"}), "sessions need a start time")
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // RoomInfo represents live room information
 type RoomInfo struct {
@@ -15,4 +18,63 @@ type RoomInfo struct {
 	Title       string    `json:"title"`
 	StartTime   time.Time `json:"start_time"`
 	EndTime     time.Time `json:"end_time"` // 新增：下播时间
-}
\ No newline at end of file
+	Online      int       `json:"online"`   // Viewer count reported by the platform, 0 if unknown
+}
+
+// LiveStatus represents the live state of a room as reported by a source
+type LiveStatus int
+
+const (
+	// StatusUnknown means the status could not be determined, e.g. on API errors
+	StatusUnknown LiveStatus = iota
+	// StatusOffline means the room is not streaming
+	StatusOffline
+	// StatusLive means the room is streaming
+	StatusLive
+	// StatusRotation means the room replays recorded videos while the
+	// streamer is offline (Bilibili 轮播)
+	StatusRotation
+)
+
+// String returns a human-readable representation of the status
+func (s LiveStatus) String() string {
+	switch s {
+	case StatusOffline:
+		return "offline"
+	case StatusLive:
+		return "live"
+	case StatusRotation:
+		return "rotation"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the status as its String form, e.g. in state files
+func (s LiveStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a status written by MarshalText
+func (s *LiveStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "unknown":
+		*s = StatusUnknown
+	case "offline":
+		*s = StatusOffline
+	case "live":
+		*s = StatusLive
+	case "rotation":
+		*s = StatusRotation
+	default:
+		return fmt.Errorf("unknown live status %q", text)
+	}
+	return nil
+}
+
+// StatusChange is a room's change from one status to another
+type StatusChange struct {
+	RoomInfo RoomInfo   `json:"room_info"`
+	From     LiveStatus `json:"from"`
+	To       LiveStatus `json:"to"`
+}
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRoomInfo(t *testing.T) {
@@ -42,4 +44,31 @@ func TestRoomInfo(t *testing.T) {
 		assert.False(t, roomInfo.IsLive)
 		assert.True(t, roomInfo.StartTime.IsZero())
 	})
-}
\ No newline at end of file
+}
+
+func TestLiveStatus(t *testing.T) {
+	assert.Equal(t, "unknown", StatusUnknown.String())
+	assert.Equal(t, "offline", StatusOffline.String())
+	assert.Equal(t, "live", StatusLive.String())
+	assert.Equal(t, "rotation", StatusRotation.String())
+	assert.Equal(t, "unknown", LiveStatus(42).String())
+
+	// The zero value must never be mistaken for a known state
+	var status LiveStatus
+	assert.Equal(t, StatusUnknown, status)
+}
+
+func TestLiveStatus_Text(t *testing.T) {
+	for _, status := range []LiveStatus{StatusUnknown, StatusOffline, StatusLive, StatusRotation} {
+		data, err := json.Marshal(status)
+		require.NoError(t, err)
+		assert.Equal(t, `"`+status.String()+`"`, string(data))
+
+		var decoded LiveStatus
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, status, decoded)
+	}
+
+	var status LiveStatus
+	assert.Error(t, json.Unmarshal([]byte(`"streaming"`), &status))
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// TitleChange records a room title set during a live session
+type TitleChange struct {
+	Time  time.Time `json:"time"`
+	Title string    `json:"title"`
+}
+
+// Session is one live session of a room, from live start to live end
+type Session struct {
+	ID           uint64        `json:"id"`
+	Platform     string        `json:"platform"`
+	RoomID       string        `json:"room_id"`
+	UName        string        `json:"uname"`
+	Title        string        `json:"title"`         // Title at live start
+	TitleChanges []TitleChange `json:"title_changes"` // Titles set after live start, oldest first
+	StartTime    time.Time     `json:"start_time"`
+	EndTime      time.Time     `json:"end_time"` // Zero while the session is open
+	PeakViewers  int           `json:"peak_viewers"`
+}
+
+// IsOpen reports whether the session has not ended yet
+func (s Session) IsOpen() bool {
+	return s.EndTime.IsZero()
+}
+
+// Duration returns how long the session lasted, or has lasted so far if it is open
+func (s Session) Duration() time.Duration {
+	if s.IsOpen() {
+		return time.Since(s.StartTime)
+	}
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// CurrentTitle returns the latest title of the session
+func (s Session) CurrentTitle() string {
+	if len(s.TitleChanges) > 0 {
+		return s.TitleChanges[len(s.TitleChanges)-1].Title
+	}
+	return s.Title
+}
+
+// Observe applies a room observation made at now while the session is
+// open, recording a title change and raising the viewer peak. It reports
+// whether the session changed.
+func (s *Session) Observe(info RoomInfo, now time.Time) bool {
+	changed := false
+	if info.Title != "" && info.Title != s.CurrentTitle() {
+		if s.Title == "" {
+			s.Title = info.Title
+		} else {
+			s.TitleChanges = append(s.TitleChanges, TitleChange{Time: now, Title: info.Title})
+		}
+		changed = true
+	}
+	if info.UName != "" && info.UName != s.UName {
+		s.UName = info.UName
+		changed = true
+	}
+	if info.Online > s.PeakViewers {
+		s.PeakViewers = info.Online
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Observe(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	session := Session{Platform: "bilibili", RoomID: "76", StartTime: start}
+
+	assert.True(t, session.Observe(RoomInfo{UName: "Alice", Title: "Morning", Online: 100}, start))
+	assert.Equal(t, "Alice", session.UName)
+	assert.Equal(t, "Morning", session.Title)
+	assert.Empty(t, session.TitleChanges)
+	assert.Equal(t, 100, session.PeakViewers)
+
+	// Nothing new
+	assert.False(t, session.Observe(RoomInfo{UName: "Alice", Title: "Morning", Online: 80}, start.Add(time.Minute)))
+	assert.False(t, session.Observe(RoomInfo{}, start.Add(time.Minute)))
+
+	later := start.Add(time.Hour)
+	assert.True(t, session.Observe(RoomInfo{Title: "Evening", Online: 250}, later))
+	assert.Equal(t, "Morning", session.Title)
+	assert.Equal(t, []TitleChange{{Time: later, Title: "Evening"}}, session.TitleChanges)
+	assert.Equal(t, "Evening", session.CurrentTitle())
+	assert.Equal(t, 250, session.PeakViewers)
+}
+
+func TestSession_Duration(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	session := Session{StartTime: start}
+	assert.True(t, session.IsOpen())
+	assert.InDelta(t, time.Hour.Seconds(), session.Duration().Seconds(), 1)
+
+	session.EndTime = start.Add(90 * time.Minute)
+	assert.False(t, session.IsOpen())
+	assert.Equal(t, 90*time.Minute, session.Duration())
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Stream protocols, formats and codecs reported by platforms
+const (
+	ProtocolHTTPStream = "http_stream" // Progressive download, e.g. HTTP-FLV
+	ProtocolHTTPHLS    = "http_hls"
+
+	FormatFLV  = "flv"
+	FormatTS   = "ts"
+	FormatFMP4 = "fmp4"
+
+	CodecAVC  = "avc"
+	CodecHEVC = "hevc"
+)
+
+// StreamCandidate is one playable variant of a live stream
+type StreamCandidate struct {
+	URL      string    `json:"url"`
+	Protocol string    `json:"protocol"`
+	Format   string    `json:"format"`
+	Codec    string    `json:"codec"`
+	Qn       int       `json:"qn"`     // Platform quality number, higher is better
+	Host     string    `json:"host"`   // CDN host serving the URL
+	Expiry   time.Time `json:"expiry"` // Zero if the URL does not expire
+}
+
+// StreamPreference selects among stream candidates
+type StreamPreference struct {
+	// Qn is the highest wanted quality number; 0 means the best available.
+	// Candidates above it are only used when nothing at or below it exists.
+	Qn int
+	// Codec is the preferred codec, CodecAVC when empty since most relay
+	// destinations only accept H.264 over FLV
+	Codec string
+}
+
+// formatRank orders formats by how well they copy into an FLV output
+var formatRank = map[string]int{
+	FormatFLV:  0,
+	FormatTS:   1,
+	FormatFMP4: 2,
+}
+
+// RankStreams returns the candidates ordered from most to least preferred:
+// by codec first, so a higher quality never outranks a codec the
+// destination may not accept, then quality, then format. The input is not
+// modified.
+func RankStreams(candidates []StreamCandidate, pref StreamPreference) []StreamCandidate {
+	codec := pref.Codec
+	if codec == "" {
+		codec = CodecAVC
+	}
+
+	ranked := make([]StreamCandidate, len(candidates))
+	copy(ranked, candidates)
+
+	withinLimit := func(c StreamCandidate) bool {
+		return pref.Qn <= 0 || c.Qn <= pref.Qn
+	}
+	formatOrder := func(c StreamCandidate) int {
+		if rank, ok := formatRank[c.Format]; ok {
+			return rank
+		}
+		return len(formatRank)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+
+		if (a.Codec == codec) != (b.Codec == codec) {
+			return a.Codec == codec
+		}
+		if withinLimit(a) != withinLimit(b) {
+			return withinLimit(a)
+		}
+		if a.Qn != b.Qn {
+			// Highest quality within the limit, otherwise the closest above it
+			if withinLimit(a) {
+				return a.Qn > b.Qn
+			}
+			return a.Qn < b.Qn
+		}
+		return formatOrder(a) < formatOrder(b)
+	})
+
+	return ranked
+}
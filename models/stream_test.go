@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankStreams(t *testing.T) {
+	candidates := []StreamCandidate{
+		{URL: "hls-hevc-10000", Format: FormatFMP4, Codec: CodecHEVC, Qn: 10000},
+		{URL: "hls-avc-10000", Format: FormatTS, Codec: CodecAVC, Qn: 10000},
+		{URL: "flv-avc-10000", Format: FormatFLV, Codec: CodecAVC, Qn: 10000},
+		{URL: "flv-avc-250", Format: FormatFLV, Codec: CodecAVC, Qn: 250},
+		{URL: "flv-avc-150", Format: FormatFLV, Codec: CodecAVC, Qn: 150},
+	}
+
+	urls := func(ranked []StreamCandidate) []string {
+		var result []string
+		for _, c := range ranked {
+			result = append(result, c.URL)
+		}
+		return result
+	}
+
+	t.Run("best quality prefers AVC over FLV", func(t *testing.T) {
+		ranked := RankStreams(candidates, StreamPreference{})
+		assert.Equal(t, []string{"flv-avc-10000", "hls-avc-10000", "flv-avc-250", "flv-avc-150", "hls-hevc-10000"}, urls(ranked))
+	})
+
+	t.Run("higher quality in another codec", func(t *testing.T) {
+		ranked := RankStreams([]StreamCandidate{
+			{URL: "flv-hevc-20000", Format: FormatFLV, Codec: CodecHEVC, Qn: 20000},
+			{URL: "hls-avc-10000", Format: FormatTS, Codec: CodecAVC, Qn: 10000},
+		}, StreamPreference{})
+		assert.Equal(t, []string{"hls-avc-10000", "flv-hevc-20000"}, urls(ranked))
+	})
+
+	t.Run("codec preference", func(t *testing.T) {
+		ranked := RankStreams(candidates, StreamPreference{Codec: CodecHEVC})
+		assert.Equal(t, "hls-hevc-10000", ranked[0].URL)
+	})
+
+	t.Run("quality limit", func(t *testing.T) {
+		ranked := RankStreams(candidates, StreamPreference{Qn: 400})
+		assert.Equal(t, []string{"flv-avc-250", "flv-avc-150", "flv-avc-10000", "hls-avc-10000", "hls-hevc-10000"}, urls(ranked))
+	})
+
+	t.Run("nothing within the limit", func(t *testing.T) {
+		ranked := RankStreams(candidates, StreamPreference{Qn: 80})
+		assert.Equal(t, "flv-avc-150", ranked[0].URL)
+	})
+
+	t.Run("input is not modified", func(t *testing.T) {
+		RankStreams(candidates, StreamPreference{Qn: 80})
+		assert.Equal(t, "hls-hevc-10000", candidates[0].URL)
+	})
+}
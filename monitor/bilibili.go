@@ -1,7 +1,9 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/nick3/restreamer_monitor_go/logger"
@@ -13,11 +15,62 @@ import (
 // BilibiliStreamSource implements StreamSource interface for Bilibili platform
 type BilibiliStreamSource struct {
 	service    *service.BilibiliService
+	danmakuMu  sync.Mutex // Guards danmaku, which the check round reads while rooms are removed
+	danmaku    *service.DanmakuClient
+	batch      *service.BilibiliBatchPoller
 	roomInfo   models.RoomInfo
 	lastStatus bool
 	logger     *logrus.Entry
 }
 
+var (
+	bilibiliBatchOnce sync.Once
+	bilibiliBatch     *service.BilibiliBatchPoller
+)
+
+// sharedBilibiliBatchPoller returns the batch poller shared by all Bilibili sources
+func sharedBilibiliBatchPoller() *service.BilibiliBatchPoller {
+	bilibiliBatchOnce.Do(func() {
+		bilibiliBatch = service.NewBilibiliBatchPoller(service.DefaultBilibiliClient())
+	})
+	return bilibiliBatch
+}
+
+func init() {
+	RegisterPlatform(Platform{
+		Name: "bilibili",
+		Factory: func(room RoomConfig, config Config) (StreamSource, error) {
+			source, err := NewBilibiliStreamSource(room.RoomID)
+			if err != nil {
+				return nil, err
+			}
+			source.batch = sharedBilibiliBatchPoller()
+			return source, nil
+		},
+		Validate:       validateBilibiliRoom,
+		Resolve: func(ctx context.Context, refs []string, _ Config) (map[string]string, error) {
+			return service.DefaultBilibiliClient().ResolveRoomRefs(ctx, refs)
+		},
+		ValidateStream: service.ValidateBilibiliStream,
+		Capabilities: Capabilities{
+			Messages:         true,
+			PlayURL:          true,
+			QualitySelection: true,
+		},
+	})
+}
+
+// validateBilibiliRoom checks the room fields a Bilibili source needs
+func validateBilibiliRoom(room RoomConfig) error {
+	if room.RoomID == "" {
+		return fmt.Errorf("room_id is required")
+	}
+	if _, err := service.ParseBilibiliRoomRef(room.RoomID); err != nil {
+		return err
+	}
+	return nil
+}
+
 // NewBilibiliStreamSource creates a new Bilibili stream source
 func NewBilibiliStreamSource(roomID string) (*BilibiliStreamSource, error) {
 	svc, err := service.NewBilibiliService(roomID)
@@ -39,31 +92,103 @@ func NewBilibiliStreamSource(roomID string) (*BilibiliStreamSource, error) {
 	}, nil
 }
 
-// GetStatus returns the current live status
-func (b *BilibiliStreamSource) GetStatus() bool {
-	status, err := b.service.GetBilibiliLiveStatus()
-	if err != nil {
-		b.logger.WithError(err).Error("Failed to get live status")
-		return false
+// GetStatus returns the current live status, using a fresh batch result
+// when one is available and a per-room request otherwise
+func (b *BilibiliStreamSource) GetStatus() (models.LiveStatus, error) {
+	return b.GetStatusContext(context.Background())
+}
+
+// GetStatusContext is like GetStatus but cancels in-flight requests when ctx is done
+func (b *BilibiliStreamSource) GetStatusContext(ctx context.Context) (models.LiveStatus, error) {
+	var batchStatus *service.BilibiliRoomStatus
+	if b.batch != nil {
+		if status, ok := b.batch.Lookup(b.roomInfo.RoomID); ok {
+			batchStatus = &status
+		}
 	}
-	
+
+	var status models.LiveStatus
+	if batchStatus != nil {
+		status = batchStatus.Status()
+	} else {
+		var err error
+		status, err = b.service.GetBilibiliLiveStatusContext(ctx)
+		if err != nil {
+			return models.StatusUnknown, fmt.Errorf("failed to get live status: %w", err)
+		}
+	}
+	isLive := status == models.StatusLive
+
 	// Update room info if status changed
-	if status != b.lastStatus {
-		b.roomInfo.IsLive = status
-		if status {
+	if isLive != b.lastStatus {
+		b.roomInfo.IsLive = isLive
+		if isLive {
 			b.roomInfo.StartTime = time.Now()
 		}
-		b.lastStatus = status
+		b.lastStatus = isLive
+	}
+
+	if batchStatus != nil {
+		b.applyBatchStatus(*batchStatus)
+	}
+
+	return status, nil
+}
+
+// ApplyEvent updates the live status from a pushed message. A pushed live
+// start begins a new session, so the start time is not left over from the
+// previous one or overwritten by the next poll.
+func (b *BilibiliStreamSource) ApplyEvent(event service.DanmakuEvent) {
+	switch event.Type {
+	case service.DanmakuEventLive:
+		if !b.lastStatus {
+			b.roomInfo.StartTime = event.Timestamp
+		}
+		b.roomInfo.IsLive = true
+		b.lastStatus = true
+	case service.DanmakuEventPreparing:
+		b.roomInfo.IsLive = false
+		b.lastStatus = false
+	}
+}
+
+// applyBatchStatus copies room metadata from a batch result
+func (b *BilibiliStreamSource) applyBatchStatus(status service.BilibiliRoomStatus) {
+	b.roomInfo.RealRoomID = status.RoomID
+	if status.UID != "" {
+		b.roomInfo.UID = status.UID
+		b.roomInfo.UName = status.UName
+	}
+	if status.Title != "" {
+		b.roomInfo.Title = status.Title
+	}
+	if status.Cover != "" {
+		b.roomInfo.UserCover = status.Cover
+	}
+	b.roomInfo.Online = status.Online
+	if status.IsLive() && !status.LiveStart.IsZero() {
+		b.roomInfo.StartTime = status.LiveStart
 	}
-	
-	return status
+}
+
+// Batcher returns the shared batch poller, or nil for stand-alone sources
+func (b *BilibiliStreamSource) Batcher() (StatusBatcher, string) {
+	if b.batch == nil {
+		return nil, b.roomInfo.RoomID
+	}
+	return b.batch, b.roomInfo.RoomID
 }
 
 // GetRoomInfo returns the room information
 func (b *BilibiliStreamSource) GetRoomInfo() models.RoomInfo {
+	return b.GetRoomInfoContext(context.Background())
+}
+
+// GetRoomInfoContext is like GetRoomInfo but cancels in-flight requests when ctx is done
+func (b *BilibiliStreamSource) GetRoomInfoContext(ctx context.Context) models.RoomInfo {
 	// Update real room ID if not set
 	if b.roomInfo.RealRoomID == "" {
-		realRoomID, err := b.service.GetBilibiliRealRoomId()
+		realRoomID, err := b.service.GetBilibiliRealRoomIdContext(ctx)
 		if err != nil {
 			b.logger.WithError(err).Error("Failed to get real room ID")
 		} else {
@@ -75,7 +200,7 @@ func (b *BilibiliStreamSource) GetRoomInfo() models.RoomInfo {
 	// Fallback approach: try multiple methods to get anchor info
 	if b.roomInfo.UID == "" || b.roomInfo.UName == "" {
 		// Try primary method: GetRoomBaseInfo (rich info, but may be rate-limited)
-		if baseInfo, err := b.service.GetRoomBaseInfo(); err == nil {
+		if baseInfo, err := b.service.GetRoomBaseInfoContext(ctx); err == nil {
 			b.roomInfo.UID = baseInfo.UID
 			b.roomInfo.UName = baseInfo.UName
 		} else {
@@ -91,7 +216,7 @@ func (b *BilibiliStreamSource) GetRoomInfo() models.RoomInfo {
 
 	// Get room title and cover (this API is more stable)
 	if b.roomInfo.Title == "" || b.roomInfo.UserCover == "" {
-		if roomInfo, err := b.service.GetRoomInfo(); err == nil {
+		if roomInfo, err := b.service.GetRoomInfoContext(ctx); err == nil {
 			b.roomInfo.Title = roomInfo.Title
 			b.roomInfo.UserCover = roomInfo.UserCover
 			b.roomInfo.Keyframe = roomInfo.Keyframe
@@ -108,38 +233,75 @@ func (b *BilibiliStreamSource) GetRoomInfo() models.RoomInfo {
 
 // GetPlayURL returns the live stream URL
 func (b *BilibiliStreamSource) GetPlayURL() string {
+	return b.GetPlayURLContext(context.Background())
+}
+
+// GetPlayURLContext is like GetPlayURL but cancels in-flight requests when ctx is done
+func (b *BilibiliStreamSource) GetPlayURLContext(ctx context.Context) string {
+	candidates, err := b.StreamCandidatesContext(ctx, "", "")
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to get live URLs")
+		return ""
+	}
+
+	// The best AVC stream, preferring FLV
+	return candidates[0].URL
+}
+
+// StreamCandidatesContext returns the room's streams ranked by codec and quality
+func (b *BilibiliStreamSource) StreamCandidatesContext(ctx context.Context, quality, codec string) ([]models.StreamCandidate, error) {
 	realRoomID := b.roomInfo.RealRoomID
 	if realRoomID == "" {
 		var err error
-		realRoomID, err = b.service.GetBilibiliRealRoomId()
+		realRoomID, err = b.service.GetBilibiliRealRoomIdContext(ctx)
 		if err != nil {
-			b.logger.WithError(err).Error("Failed to get real room ID")
-			return ""
+			return nil, fmt.Errorf("failed to get real room ID: %w", err)
 		}
 		b.roomInfo.RealRoomID = realRoomID
 	}
-	
-	urls, err := b.service.GetBilibiliLiveRealURL(realRoomID)
-	if err != nil {
-		b.logger.WithError(err).Error("Failed to get live URLs")
-		return ""
-	}
 
-	if len(urls) > 0 {
-		return urls[0] // Return the first URL (usually M3U8 format)
-	}
-
-	return ""
+	return b.service.GetStreamCandidatesContext(ctx, realRoomID, quality, codec)
 }
 
-// StartMsgListener starts listening for live messages (placeholder)
+// StartMsgListener connects to the room's message servers in the background
 func (b *BilibiliStreamSource) StartMsgListener() {
+	b.danmakuMu.Lock()
+	defer b.danmakuMu.Unlock()
+	if b.danmaku != nil {
+		return
+	}
+
 	b.logger.WithField("room_id", b.roomInfo.RoomID).Info("Starting message listener")
-	// TODO: Implement WebSocket connection for live messages
+	b.danmaku = b.service.NewDanmakuClient()
+	b.danmaku.Start()
 }
 
-// CloseMsgListener closes the message listener (placeholder)
+// CloseMsgListener closes the message listener and its event channel
 func (b *BilibiliStreamSource) CloseMsgListener() {
+	b.danmakuMu.Lock()
+	defer b.danmakuMu.Unlock()
+	if b.danmaku == nil {
+		return
+	}
+
 	b.logger.WithField("room_id", b.roomInfo.RoomID).Info("Closing message listener")
-	// TODO: Implement WebSocket connection cleanup
-}
\ No newline at end of file
+	b.danmaku.Close()
+	b.danmaku = nil
+}
+
+// Events returns the decoded room messages, or nil if the listener is not started
+func (b *BilibiliStreamSource) Events() <-chan service.DanmakuEvent {
+	b.danmakuMu.Lock()
+	defer b.danmakuMu.Unlock()
+	if b.danmaku == nil {
+		return nil
+	}
+	return b.danmaku.Events()
+}
+
+// MsgConnected reports whether the message listener holds a live connection
+func (b *BilibiliStreamSource) MsgConnected() bool {
+	b.danmakuMu.Lock()
+	defer b.danmakuMu.Unlock()
+	return b.danmaku != nil && b.danmaku.Connected()
+}
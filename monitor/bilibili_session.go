@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/notification"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+// bilibiliSessionWatcher verifies a Bilibili login
+type bilibiliSessionWatcher struct {
+	client  *service.BilibiliClient
+	timeout time.Duration
+	verbose bool
+	// onExpired is called when a valid login stops working
+	onExpired func()
+	logger    *logrus.Entry
+}
+
+// WatchBilibiliSession verifies the Bilibili login of config every session
+// check interval until ctx is done. It is used by both the monitor and the
+// relay manager; onExpired, if set, is called when a valid login stops working.
+func WatchBilibiliSession(ctx context.Context, client *service.BilibiliClient, config Config, onExpired func()) {
+	w := &bilibiliSessionWatcher{
+		client:    client,
+		timeout:   config.StatusCheckTimeout(),
+		verbose:   config.Verbose,
+		onExpired: onExpired,
+		logger:    logger.GetLogger(map[string]interface{}{"component": "monitor", "platform": "bilibili", "module": "session"}),
+	}
+	w.run(ctx, config.Platforms.Bilibili.SessionCheckPeriod())
+}
+
+// run checks the login every interval until ctx is done
+func (w *bilibiliSessionWatcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	valid := w.check(ctx, true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			valid = w.check(ctx, valid)
+		}
+	}
+}
+
+// check checks the login once and returns whether it is valid. onExpired
+// is called when a valid login stops working; a check that fails keeps the
+// previous state.
+func (w *bilibiliSessionWatcher) check(ctx context.Context, wasValid bool) bool {
+	checkCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	session, err := w.client.CheckSession(checkCtx)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.WithError(err).Warn("Failed to check Bilibili login")
+		}
+		return wasValid
+	}
+
+	if session.LoggedIn {
+		if !wasValid {
+			w.logger.WithField("uname", session.UName).Info("Bilibili login is valid again")
+		} else if w.verbose {
+			w.logger.WithFields(logrus.Fields{
+				"uid":   session.UID,
+				"uname": session.UName,
+			}).Debug("Bilibili login is valid")
+		}
+		return true
+	}
+
+	if wasValid {
+		w.logger.Error("Bilibili login has expired, requests continue without login")
+		if w.onExpired != nil {
+			w.onExpired()
+		}
+	}
+	return false
+}
+
+// watchBilibiliSession verifies the configured Bilibili login until the
+// monitor stops, notifying admins when it expires
+func (m *Monitor) watchBilibiliSession(client *service.BilibiliClient) {
+	WatchBilibiliSession(m.ctx, client, m.config, m.notifyBilibiliSessionExpired)
+}
+
+// notifyBilibiliSessionExpired tells admins to renew the Bilibili login
+func (m *Monitor) notifyBilibiliSessionExpired() {
+	NotifyBilibiliSessionExpired(m.notificationMgr)
+}
+
+// NotifyBilibiliSessionExpired tells admins through mgr, if set, to renew
+// the Bilibili login
+func NotifyBilibiliSessionExpired(mgr *notification.NotificationManager) {
+	if mgr != nil {
+		mgr.SendErrorNotification("Bilibili 登录已失效", "Cookie 已过期或被注销，请更新配置中的 platforms.bilibili 登录信息")
+	}
+}
+
+// hasPlatform reports whether any monitored room is on the platform
+func (m *Monitor) hasPlatform(name string) bool {
+	for _, room := range m.rooms {
+		if room.Platform == name {
+			return true
+		}
+	}
+	return false
+}
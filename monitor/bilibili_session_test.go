@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBilibiliSessionWatcher_Check(t *testing.T) {
+	var loggedIn, failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case failing.Load():
+			w.WriteHeader(http.StatusBadGateway)
+		case loggedIn.Load():
+			cookie, err := r.Cookie("SESSDATA")
+			assert.NoError(t, err)
+			assert.Equal(t, "session", cookie.Value)
+			_, _ = w.Write([]byte(`{"code":0,"data":{"isLogin":true,"mid":1001,"uname":"Alice"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"isLogin":false}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetRetryCount(0)
+	client.Wbi.NavURL = server.URL
+	require.NoError(t, client.Configure(BilibiliConfig{Credentials: &service.BilibiliCredentials{SESSDATA: "session"}}))
+
+	var expired int
+	w := &bilibiliSessionWatcher{
+		client:    client,
+		timeout:   time.Second,
+		onExpired: func() { expired++ },
+		logger:    logrus.NewEntry(logrus.New()),
+	}
+	ctx := context.Background()
+
+	loggedIn.Store(true)
+	assert.True(t, w.check(ctx, true))
+
+	// The cookie expires, which is reported once
+	loggedIn.Store(false)
+	assert.False(t, w.check(ctx, true))
+	assert.False(t, w.check(ctx, false))
+	assert.Equal(t, 1, expired)
+
+	// A failed check keeps the previous state
+	failing.Store(true)
+	assert.False(t, w.check(ctx, false))
+	assert.True(t, w.check(ctx, true))
+
+	// The cookie is renewed
+	failing.Store(false)
+	loggedIn.Store(true)
+	assert.True(t, w.check(ctx, false))
+	assert.Equal(t, 1, expired)
+}
+
+func TestMonitor_HasPlatform(t *testing.T) {
+	m := newTestMonitor(t, Config{Rooms: []RoomConfig{{Platform: "bilibili", RoomID: "76", Enabled: true}}}, nil)
+	assert.True(t, m.hasPlatform("bilibili"))
+	assert.False(t, m.hasPlatform("douyu"))
+}
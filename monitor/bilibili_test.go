@@ -1,8 +1,14 @@
 package monitor
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -27,13 +33,19 @@ func TestBilibiliStreamSource_GetStatus(t *testing.T) {
 	source, err := NewBilibiliStreamSource("76")
 	require.NoError(t, err)
 
-	// First call should work (may return false if room is offline)
-	status := source.GetStatus()
-	assert.IsType(t, false, status)
+	// First call should work (may return offline, or unknown if the API is unreachable)
+	status, err := source.GetStatus()
+	if err != nil {
+		assert.Equal(t, models.StatusUnknown, status)
+	} else {
+		assert.NotEqual(t, models.StatusUnknown, status)
+	}
 
 	// Second call should also work
-	status2 := source.GetStatus()
-	assert.IsType(t, false, status2)
+	status2, err := source.GetStatus()
+	if err != nil {
+		assert.Equal(t, models.StatusUnknown, status2)
+	}
 }
 
 func TestBilibiliStreamSource_GetRoomInfo(t *testing.T) {
@@ -60,12 +72,126 @@ func TestBilibiliStreamSource_MessageListener(t *testing.T) {
 	source, err := NewBilibiliStreamSource("76")
 	require.NoError(t, err)
 
+	assert.Nil(t, source.Events())
+
 	// These should not panic
 	assert.NotPanics(t, func() {
 		source.StartMsgListener()
 	})
+	assert.NotNil(t, source.Events())
+
+	assert.NotPanics(t, func() {
+		source.CloseMsgListener()
+	})
+	assert.Nil(t, source.Events())
 
+	// Closing twice is a no-op
 	assert.NotPanics(t, func() {
 		source.CloseMsgListener()
 	})
-}
\ No newline at end of file
+}
+func TestBilibiliStreamSource_BatchStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nav" {
+			_, _ = w.Write([]byte(`{"code":-101,"data":{"wbi_img":{"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png","sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+			return
+		}
+		assert.Equal(t, []string{"76"}, r.URL.Query()["room_ids"])
+		assert.NotEmpty(t, r.URL.Query().Get("w_rid"))
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"by_room_ids":{"22637261":{"room_id":22637261,"short_id":76,"uid":1001,"uname":"Alice","title":"Live now","cover":"https://i0.hdslb.com/cover.jpg","live_status":1,"live_time":"2024-03-01 20:00:00"}}}}`))
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.Wbi.NavURL = server.URL + "/nav"
+	poller := service.NewBilibiliBatchPoller(client)
+
+	source, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+	source.batch = poller
+
+	batcher, roomID := source.Batcher()
+	assert.Equal(t, "76", roomID)
+	require.NoError(t, batcher.Refresh(context.Background(), []string{roomID}))
+
+	// The batch result answers the check without a per-room request
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+	assert.Equal(t, "22637261", source.roomInfo.RealRoomID)
+	assert.Equal(t, "1001", source.roomInfo.UID)
+	assert.Equal(t, "Alice", source.roomInfo.UName)
+	assert.Equal(t, "Live now", source.roomInfo.Title)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), source.roomInfo.StartTime.UTC())
+
+	// Stand-alone sources poll on their own
+	standalone, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+	batcher, _ = standalone.Batcher()
+	assert.Nil(t, batcher)
+}
+
+func TestBilibiliStreamSource_PushedSessions(t *testing.T) {
+	source, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+
+	first := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	source.ApplyEvent(service.DanmakuEvent{Type: service.DanmakuEventLive, Timestamp: first})
+	assert.True(t, source.roomInfo.IsLive)
+	assert.Equal(t, first, source.roomInfo.StartTime)
+
+	// Repeated live messages belong to the same session
+	source.ApplyEvent(service.DanmakuEvent{Type: service.DanmakuEventLive, Timestamp: first.Add(time.Minute)})
+	assert.Equal(t, first, source.roomInfo.StartTime)
+
+	source.ApplyEvent(service.DanmakuEvent{Type: service.DanmakuEventPreparing, Timestamp: first.Add(time.Hour)})
+	assert.False(t, source.roomInfo.IsLive)
+
+	// The next session starts when it is pushed, not when the first one did
+	source.ApplyEvent(service.DanmakuEvent{Type: service.DanmakuEventLive, Timestamp: second})
+	assert.True(t, source.roomInfo.IsLive)
+	assert.Equal(t, second, source.roomInfo.StartTime)
+}
+
+func TestBilibiliStreamSource_StreamCandidates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nav":
+			_, _ = w.Write([]byte(`{"code":-101,"data":{"wbi_img":{"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png","sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+		case "/room/v1/Room/room_init":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"room_id":22637261,"short_id":76,"live_status":1}}`))
+		case "/xlive/web-room/v2/index/getRoomPlayInfo":
+			assert.Equal(t, "22637261", r.URL.Query().Get("room_id"))
+			_, _ = w.Write([]byte(`{"code":0,"data":{"playurl_info":{"playurl":{"stream":[{"protocol_name":"http_stream","format":[{"format_name":"flv","codec":[
+				{"codec_name":"hevc","current_qn":10000,"base_url":"/live/hevc.flv?","url_info":[{"host":"https://cdn.example.com","extra":"expires=1700003600"}]},
+				{"codec_name":"avc","current_qn":10000,"base_url":"/live/avc.flv?","url_info":[{"host":"https://cdn.example.com","extra":"expires=1700003600"}]}]}]}]}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.Wbi.NavURL = server.URL + "/nav"
+
+	source, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+	source.service, err = service.NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// The play URL defaults to the best AVC stream
+	assert.Equal(t, "https://cdn.example.com/live/avc.flv?expires=1700003600", source.GetPlayURL())
+	assert.Equal(t, "22637261", source.roomInfo.RealRoomID)
+
+	candidates, err := source.StreamCandidatesContext(context.Background(), "best", "hevc")
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+	assert.Equal(t, models.CodecHEVC, candidates[0].Codec)
+	assert.Equal(t, "cdn.example.com", candidates[0].Host)
+
+	var _ CandidateSource = source
+}
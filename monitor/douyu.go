@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+// DouyuStreamSource implements StreamSource interface for Douyu platform
+type DouyuStreamSource struct {
+	service    *service.DouyuService
+	roomInfo   models.RoomInfo
+	lastStatus bool
+	logger     *logrus.Entry
+}
+
+func init() {
+	RegisterPlatform(Platform{
+		Name: "douyu",
+		Factory: func(room RoomConfig, config Config) (StreamSource, error) {
+			return NewDouyuStreamSourceWithConfig(room.RoomID, config.Platforms.Douyu)
+		},
+		Validate: validateDouyuRoom,
+		Capabilities: Capabilities{
+			PlayURL: true,
+		},
+	})
+}
+
+// validateDouyuRoom checks the room fields a Douyu source needs
+func validateDouyuRoom(room RoomConfig) error {
+	if room.RoomID == "" {
+		return fmt.Errorf("room_id is required")
+	}
+	if err := service.ValidateDouyuRoomID(room.RoomID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewDouyuStreamSource creates a new Douyu stream source
+func NewDouyuStreamSource(roomID string) (*DouyuStreamSource, error) {
+	return NewDouyuStreamSourceWithConfig(roomID, DouyuConfig{})
+}
+
+// NewDouyuStreamSourceWithConfig creates a Douyu stream source that uses the platform settings
+func NewDouyuStreamSourceWithConfig(roomID string, config DouyuConfig) (*DouyuStreamSource, error) {
+	svc, err := service.NewDouyuServiceWithConfig(roomID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DouyuStreamSource{
+		service: svc,
+		roomInfo: models.RoomInfo{
+			Platform: "douyu",
+			RoomID:   roomID,
+		},
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "monitor",
+			"platform":  "douyu",
+			"room_id":   roomID,
+		}),
+	}, nil
+}
+
+// GetStatus returns the current live status.
+// The same API call also refreshes the room metadata.
+func (d *DouyuStreamSource) GetStatus() (models.LiveStatus, error) {
+	info, err := d.service.GetDouyuRoomInfo()
+	if err != nil {
+		return models.StatusUnknown, fmt.Errorf("failed to get live status: %w", err)
+	}
+
+	// Update start time if status changed, the API value wins when present
+	if info.IsLive != d.lastStatus {
+		if info.IsLive {
+			d.roomInfo.StartTime = time.Now()
+		}
+		d.lastStatus = info.IsLive
+	}
+	d.applyRoomInfo(info)
+
+	if info.IsLive {
+		return models.StatusLive, nil
+	}
+	return models.StatusOffline, nil
+}
+
+// GetRoomInfo returns the room information
+func (d *DouyuStreamSource) GetRoomInfo() models.RoomInfo {
+	if d.roomInfo.RealRoomID == "" || d.roomInfo.UName == "" {
+		if info, err := d.service.GetDouyuRoomInfo(); err == nil {
+			d.applyRoomInfo(info)
+		} else {
+			d.logger.WithError(err).Error("Failed to get room info")
+
+			// Fallback: use default values to ensure notifications still work
+			if d.roomInfo.UName == "" {
+				d.roomInfo.UName = fmt.Sprintf("主播%s", d.roomInfo.RoomID)
+				d.logger.WithField("anchor_name", d.roomInfo.UName).Warn("Using default anchor name")
+			}
+		}
+	}
+
+	return d.roomInfo
+}
+
+// applyRoomInfo copies metadata from the API into the room info
+func (d *DouyuStreamSource) applyRoomInfo(info *service.DouyuRoomInfo) {
+	d.roomInfo.IsLive = info.IsLive
+	if info.RoomID != "" {
+		d.roomInfo.RealRoomID = info.RoomID
+	}
+	if info.OwnerName != "" {
+		d.roomInfo.UName = info.OwnerName
+	}
+	d.roomInfo.Title = info.Title
+	d.roomInfo.UserCover = info.Thumb
+	d.roomInfo.Keyframe = info.Thumb
+	if info.IsLive && !info.StartTime.IsZero() {
+		d.roomInfo.StartTime = info.StartTime
+	}
+}
+
+// GetPlayURL returns the live stream URL
+func (d *DouyuStreamSource) GetPlayURL() string {
+	// Aliases have to be resolved to the numeric room ID first
+	if d.roomInfo.RealRoomID == "" {
+		info, err := d.service.GetDouyuRoomInfo()
+		if err != nil {
+			d.logger.WithError(err).Error("Failed to get real room ID")
+			return ""
+		}
+		d.applyRoomInfo(info)
+	}
+
+	playURL, err := d.service.GetDouyuLiveRealURL(d.roomInfo.RealRoomID)
+	if err != nil {
+		d.logger.WithError(err).Error("Failed to get live URL")
+		return ""
+	}
+
+	return playURL
+}
+
+// StartMsgListener is a no-op, Douyu room messages are not supported
+func (d *DouyuStreamSource) StartMsgListener() {
+	d.logger.WithField("room_id", d.roomInfo.RoomID).Debug("Room messages are not supported for Douyu, using polling")
+}
+
+// CloseMsgListener is a no-op, Douyu room messages are not supported
+func (d *DouyuStreamSource) CloseMsgListener() {}
@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDouyuTestSource returns a Douyu source backed by a local server with recorded responses
+func newDouyuTestSource(t *testing.T, roomID string, roomResponse string) *DouyuStreamSource {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/RoomApi/room/"+roomID, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(roomResponse))
+	})
+	mux.HandleFunc("/wgapi/livenc/liveweb/websec/getEncryption", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"error":0,"msg":"","data":{"key":"k","rand_str":"r","enc_time":1,"enc_data":"E","is_special":0}}`))
+	})
+	mux.HandleFunc("/lapi/live/getH5PlayV1/288016", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"error":0,"msg":"ok","data":{"rtmp_url":"https://cdn.example.com/live","rtmp_live":"288016.flv?token=t"}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	source, err := NewDouyuStreamSource(roomID)
+	require.NoError(t, err)
+	source.service.Client.SetBaseURL(server.URL).SetRetryCount(0)
+	source.service.OpenClient.SetBaseURL(server.URL).SetRetryCount(0)
+	return source
+}
+
+func TestNewDouyuStreamSource(t *testing.T) {
+	source, err := NewDouyuStreamSource("288016")
+	require.NoError(t, err)
+	assert.Equal(t, "douyu", source.roomInfo.Platform)
+	assert.Equal(t, "288016", source.roomInfo.RoomID)
+
+	_, err = NewDouyuStreamSource("bad/id")
+	assert.Error(t, err)
+
+	p, ok := LookupPlatform("douyu")
+	require.True(t, ok)
+	assert.True(t, p.Capabilities.PlayURL)
+	assert.False(t, p.Capabilities.Messages)
+}
+
+func TestDouyuStreamSource_LiveRoom(t *testing.T) {
+	// The room is configured by alias and resolved to its numeric ID
+	source := newDouyuTestSource(t, "qiezi", `{"error":0,"data":{"room_id":"288016","room_thumb":"https://rpic.douyucdn.cn/288016.png","room_name":"标题","room_status":"1","start_time":"2024-03-01 20:05","owner_name":"主播A","avatar":""}}`)
+
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	info := source.GetRoomInfo()
+	assert.Equal(t, "douyu", info.Platform)
+	assert.Equal(t, "qiezi", info.RoomID)
+	assert.Equal(t, "288016", info.RealRoomID)
+	assert.Equal(t, "主播A", info.UName)
+	assert.Equal(t, "标题", info.Title)
+	assert.Equal(t, "https://rpic.douyucdn.cn/288016.png", info.UserCover)
+	assert.True(t, info.IsLive)
+	assert.Equal(t, 2024, info.StartTime.Year())
+
+	assert.Equal(t, "https://cdn.example.com/live/288016.flv?token=t", source.GetPlayURL())
+}
+
+func TestDouyuStreamSource_Errors(t *testing.T) {
+	source := newDouyuTestSource(t, "288016", `{"error":101,"data":"房间未找到"}`)
+
+	status, err := source.GetStatus()
+	assert.Error(t, err)
+	assert.Equal(t, models.StatusUnknown, status)
+
+	info := source.GetRoomInfo()
+	assert.Equal(t, "主播288016", info.UName)
+	assert.Empty(t, source.GetPlayURL())
+}
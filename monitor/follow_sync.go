@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFollowSyncInterval = time.Hour
+	// followSyncTimeout bounds one sync, which may page through a long follow list
+	followSyncTimeout = 2 * time.Minute
+)
+
+// FollowSyncConfig imports the live rooms of the streamers a Bilibili
+// account follows. Imported rooms are dropped again when they are unfollowed;
+// rooms listed in rooms are never touched.
+type FollowSyncConfig struct {
+	UID      string   `json:"uid"`
+	Cookie   string   `json:"cookie,omitempty"`   // Cookie header of the account, needed for private or long follow lists
+	Interval string   `json:"interval,omitempty"` // How often the follow list is synced (default 1h)
+	Include  []string `json:"include,omitempty"`  // Only import these UIDs or room IDs
+	Exclude  []string `json:"exclude,omitempty"`  // Never import these UIDs or room IDs
+}
+
+// Validate checks the follow sync settings
+func (c FollowSyncConfig) Validate() error {
+	if c.UID == "" {
+		return fmt.Errorf("uid is required")
+	}
+	ref, err := service.ParseBilibiliRoomRef(c.UID)
+	if err != nil || ref.Kind != service.RoomRefRoomID {
+		return fmt.Errorf("invalid uid %q, expected a numeric UID", c.UID)
+	}
+	if c.Interval != "" {
+		if interval, err := time.ParseDuration(c.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval %q", c.Interval)
+		}
+	}
+	return nil
+}
+
+// SyncInterval returns the interval between syncs
+func (c FollowSyncConfig) SyncInterval() time.Duration {
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 {
+		return defaultFollowSyncInterval
+	}
+	return interval
+}
+
+// Allows reports whether a followed room passes the include and exclude lists
+func (c FollowSyncConfig) Allows(room service.BilibiliFollowedRoom) bool {
+	matches := func(list []string) bool {
+		for _, entry := range list {
+			entry = strings.TrimSpace(entry)
+			if entry == room.UID || entry == room.RoomID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(c.Include) > 0 && !matches(c.Include) {
+		return false
+	}
+	return !matches(c.Exclude)
+}
+
+// watchFollows syncs the follow list every interval until the monitor stops
+func (m *Monitor) watchFollows(client *service.BilibiliClient, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.syncFollows(client, true)
+		}
+	}
+}
+
+// syncFollows imports the live rooms of followed streamers and drops
+// imported rooms that were unfollowed, then reports the changes to admins.
+// A failed sync changes nothing. startListeners starts the message listeners
+// of imported rooms, which Run does itself for rooms imported before it starts them.
+func (m *Monitor) syncFollows(client *service.BilibiliClient, startListeners bool) {
+	ctx, cancel := context.WithTimeout(m.ctx, followSyncTimeout)
+	defer cancel()
+
+	followed, err := client.FollowedRooms(ctx, m.config.FollowSync.UID, m.config.FollowSync.Cookie)
+	if err != nil {
+		if m.ctx.Err() == nil {
+			m.logger.WithError(err).Warn("Failed to sync Bilibili follow list")
+		}
+		return
+	}
+
+	added, removed := m.applyFollows(followed, startListeners)
+	if len(added) == 0 && len(removed) == 0 {
+		if m.config.Verbose {
+			m.logger.WithField("followed", len(followed)).Debug("Bilibili follow list unchanged")
+		}
+		return
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"added":   len(added),
+		"removed": len(removed),
+	}).Info("Synced rooms from Bilibili follow list")
+	if m.notificationMgr != nil {
+		m.notificationMgr.SendSystemNotification(followSyncMessage(added, removed))
+	}
+}
+
+// applyFollows makes the imported rooms match the followed rooms that pass
+// the include and exclude lists, and returns the rooms it added and removed.
+// Rooms that are already monitored are left alone.
+func (m *Monitor) applyFollows(followed []service.BilibiliFollowedRoom, startListeners bool) (added, removed []service.BilibiliFollowedRoom) {
+	wanted := make(map[string]bool)
+	for _, room := range followed {
+		if !m.config.FollowSync.Allows(room) {
+			continue
+		}
+
+		key := fmt.Sprintf("bilibili:%s", room.RoomID)
+		wanted[key] = true
+		if _, imported := m.followed[key]; imported {
+			continue
+		}
+
+		m.mu.RLock()
+		_, exists := m.sources[key]
+		m.mu.RUnlock()
+		if exists {
+			continue
+		}
+
+		roomConfig := RoomConfig{Platform: "bilibili", RoomID: room.RoomID, Enabled: true}
+		source, err := NewStreamSource(roomConfig, m.config)
+		if err != nil {
+			m.logger.WithError(err).WithField("uid", room.UID).Warnf("Failed to create source for followed room %s", room.RoomID)
+			continue
+		}
+
+		m.mu.Lock()
+		m.sources[key] = source
+		m.rooms[key] = roomConfig
+		m.mu.Unlock()
+		m.followed[key] = room
+		if startListeners {
+			m.startMsgListener(key, source)
+		}
+		added = append(added, room)
+	}
+
+	for key, room := range m.followed {
+		if wanted[key] {
+			continue
+		}
+		m.removeSource(key)
+		delete(m.followed, key)
+		removed = append(removed, room)
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].RoomID < removed[j].RoomID })
+
+	return added, removed
+}
+
+// removeSource stops monitoring a room and forgets its state
+func (m *Monitor) removeSource(key string) {
+	m.mu.Lock()
+	source, exists := m.sources[key]
+	delete(m.sources, key)
+	delete(m.rooms, key)
+	delete(m.lastStatus, key)
+	delete(m.failures, key)
+	delete(m.pendingOffline, key)
+	delete(m.sessionStart, key)
+	delete(m.riskControlled, key)
+	delete(m.lastNotices, key)
+	delete(m.restored, key)
+	m.mu.Unlock()
+
+	if exists {
+		// Wait for a running check or pushed event to finish with the source
+		lock := m.sourceLock(key)
+		lock.Lock()
+		source.CloseMsgListener()
+		lock.Unlock()
+	}
+	m.closeSession(key, time.Now())
+}
+
+// followSyncMessage formats the admin notification for a sync
+func followSyncMessage(added, removed []service.BilibiliFollowedRoom) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "关注列表同步完成：新增 %d 个直播间，移除 %d 个直播间", len(added), len(removed))
+
+	list := func(title string, rooms []service.BilibiliFollowedRoom) {
+		if len(rooms) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n\n%s：", title)
+		for _, room := range rooms {
+			fmt.Fprintf(&b, "\n• %s（房间 %s，UID %s）", room.UName, room.RoomID, room.UID)
+		}
+	}
+	list("新增", added)
+	list("移除", removed)
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowSyncConfig_Allows(t *testing.T) {
+	alice := service.BilibiliFollowedRoom{UID: "1", UName: "Alice", RoomID: "100"}
+	bob := service.BilibiliFollowedRoom{UID: "2", UName: "Bob", RoomID: "200"}
+
+	config := FollowSyncConfig{UID: "1001"}
+	assert.True(t, config.Allows(alice))
+	assert.True(t, config.Allows(bob))
+
+	// Entries match the UID or the room ID
+	config.Include = []string{"1", "200"}
+	assert.True(t, config.Allows(alice))
+	assert.True(t, config.Allows(bob))
+
+	config.Exclude = []string{"200"}
+	assert.True(t, config.Allows(alice))
+	assert.False(t, config.Allows(bob))
+
+	config = FollowSyncConfig{UID: "1001", Include: []string{"1"}}
+	assert.False(t, config.Allows(bob))
+}
+
+func TestFollowSyncConfig_SyncInterval(t *testing.T) {
+	assert.Equal(t, defaultFollowSyncInterval, FollowSyncConfig{}.SyncInterval())
+	assert.Equal(t, 10*time.Minute, FollowSyncConfig{Interval: "10m"}.SyncInterval())
+	assert.Error(t, FollowSyncConfig{}.Validate())
+	assert.ErrorContains(t, FollowSyncConfig{UID: "uid:1001"}.Validate(), "expected a numeric UID")
+}
+
+func TestMonitor_SyncFollows(t *testing.T) {
+	// The account follows Alice (room 100) and Bob (room 200) at first,
+	// then unfollows Alice; room 300 is configured by hand
+	var unfollowed, failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case failing.Load():
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/x/relation/followings" && unfollowed.Load():
+			_, _ = w.Write([]byte(`{"code":0,"data":{"list":[{"mid":2},{"mid":3},{"mid":4}],"total":3}}`))
+		case r.URL.Path == "/x/relation/followings":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"list":[{"mid":1},{"mid":2},{"mid":3},{"mid":4}],"total":4}}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":0,"data":{
+				"1":{"uname":"Alice","room_id":100},
+				"2":{"uname":"Bob","room_id":200},
+				"3":{"uname":"Carol","room_id":300},
+				"4":{"uname":"Dave","room_id":400}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.FollowingsURL = server.URL + "/x/relation/followings"
+
+	manual := newFakeSource("300")
+	config := Config{
+		Rooms:      []RoomConfig{{Platform: "bilibili", RoomID: "300", Enabled: true}},
+		FollowSync: &FollowSyncConfig{UID: "1001", Exclude: []string{"4"}},
+	}
+	m := newTestMonitor(t, config, map[string]StreamSource{"bilibili:300": manual})
+
+	m.syncFollows(client, false)
+	assert.Len(t, m.sources, 3)
+	assert.Contains(t, m.sources, "bilibili:100")
+	assert.Contains(t, m.sources, "bilibili:200")
+	assert.Same(t, manual, m.sources["bilibili:300"], "configured rooms are kept as they are")
+	assert.NotContains(t, m.sources, "bilibili:400", "excluded rooms are not imported")
+	assert.Equal(t, RoomConfig{Platform: "bilibili", RoomID: "100", Enabled: true}, m.rooms["bilibili:100"])
+
+	// A failed sync changes nothing
+	m.lastStatus["bilibili:100"] = models.StatusLive
+	failing.Store(true)
+	m.syncFollows(client, false)
+	assert.Len(t, m.sources, 3)
+
+	// Unfollowed rooms are dropped along with their state
+	failing.Store(false)
+	unfollowed.Store(true)
+	m.syncFollows(client, false)
+	assert.NotContains(t, m.sources, "bilibili:100")
+	assert.NotContains(t, m.rooms, "bilibili:100")
+	assert.NotContains(t, m.lastStatus, "bilibili:100")
+	assert.Contains(t, m.sources, "bilibili:200")
+	assert.Contains(t, m.sources, "bilibili:300")
+	require.Len(t, m.followed, 1)
+}
+
+func TestFollowSyncMessage(t *testing.T) {
+	message := followSyncMessage(
+		[]service.BilibiliFollowedRoom{{UID: "2", UName: "Bob", RoomID: "200"}},
+		[]service.BilibiliFollowedRoom{{UID: "1", UName: "Alice", RoomID: "100"}},
+	)
+	assert.Contains(t, message, "新增 1 个直播间，移除 1 个直播间")
+	assert.Contains(t, message, "新增：\n• Bob（房间 200，UID 2）")
+	assert.Contains(t, message, "移除：\n• Alice（房间 100，UID 1）")
+
+	message = followSyncMessage([]service.BilibiliFollowedRoom{{UID: "2", UName: "Bob", RoomID: "200"}}, nil)
+	assert.NotContains(t, message, "移除：")
+}
+
+func TestMonitor_RemoveSourceDuringCheck(t *testing.T) {
+	source := newFakeSource("1")
+	source.setStatus(models.StatusUnknown, errors.New("boom"))
+	source.gate = make(chan struct{})
+	m := newTestMonitor(t, Config{CheckTimeout: "1h"}, map[string]StreamSource{"fake:1": source})
+
+	checked := make(chan struct{})
+	go func() {
+		m.checkSource("fake:1", source)
+		close(checked)
+	}()
+	assert.Eventually(t, func() bool { return source.checkCount() == 1 }, time.Second, time.Millisecond)
+
+	// The listener is closed only once the running check is done
+	removed := make(chan struct{})
+	go func() {
+		m.removeSource("fake:1")
+		close(removed)
+	}()
+	assert.Never(t, func() bool {
+		select {
+		case <-removed:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, 5*time.Millisecond)
+
+	// The late result is dropped instead of recreating the room's state
+	close(source.gate)
+	<-checked
+	<-removed
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	assert.NotContains(t, m.failures, "fake:1")
+	assert.NotContains(t, m.riskControlled, "fake:1")
+}
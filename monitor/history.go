@@ -0,0 +1,152 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/history"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/sirupsen/logrus"
+)
+
+// openHistory opens the session history and picks up the sessions that
+// were still open when the monitor last stopped. They are continued if the
+// room is still live at its first check and closed otherwise.
+func (m *Monitor) openHistory(path string) error {
+	store, err := history.Open(path)
+	if err != nil {
+		return err
+	}
+
+	open, err := store.OpenSessions()
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to load open live sessions")
+	}
+
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	m.history = store
+	for i := range open {
+		session := open[i]
+		m.sessions[history.RoomKey(session.Platform, session.RoomID)] = &session
+	}
+	return nil
+}
+
+// closeHistory closes the session history. Open sessions stay open in the
+// store, since the streams have not ended.
+func (m *Monitor) closeHistory() {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	if m.history == nil {
+		return
+	}
+	if err := m.history.Close(); err != nil {
+		m.logger.WithError(err).Warn("Failed to close session history")
+	}
+	m.history = nil
+}
+
+// recordSession applies a room's status to its live session: a live room
+// opens a session or updates the open one with its title and viewer count,
+// and a room that is no longer live closes its session at endTime. liveSince
+// is when the monitor saw the room go live.
+func (m *Monitor) recordSession(key string, roomInfo models.RoomInfo, liveSince, endTime time.Time) {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	if m.history == nil {
+		return
+	}
+
+	now := time.Now()
+	session, open := m.sessions[key]
+	switch {
+	case roomInfo.IsLive && !open:
+		start := m.sessionStartTime(roomInfo, liveSince, now)
+		session = &models.Session{Platform: roomInfo.Platform, RoomID: roomInfo.RoomID, StartTime: start}
+		session.Observe(roomInfo, now)
+		m.sessions[key] = session
+	case roomInfo.IsLive:
+		if !session.Observe(roomInfo, now) {
+			return
+		}
+	case open:
+		session.EndTime = endTime
+		delete(m.sessions, key)
+	default:
+		return
+	}
+
+	m.saveSession(key, session)
+}
+
+// sessionStartTime picks the start of a new session: the platform's start
+// time unless it is missing, in the future or overlaps the room's recorded
+// sessions, as a start time left over from the previous stream does, and
+// the time the monitor saw the room go live otherwise. Must be called with
+// m.historyMu held.
+func (m *Monitor) sessionStartTime(roomInfo models.RoomInfo, liveSince, now time.Time) time.Time {
+	fallback := liveSince
+	if fallback.IsZero() || fallback.After(now) {
+		fallback = now
+	}
+
+	start := roomInfo.StartTime
+	if start.IsZero() || start.After(now) {
+		return fallback
+	}
+	previous, err := m.history.Sessions(roomInfo.Platform, roomInfo.RoomID, start, time.Time{})
+	if err != nil || len(previous) > 0 {
+		return fallback
+	}
+	return start
+}
+
+// closeSession ends a room's open session at endTime, e.g. when the room
+// is no longer monitored
+func (m *Monitor) closeSession(key string, endTime time.Time) {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	session, open := m.sessions[key]
+	if m.history == nil || !open {
+		return
+	}
+	session.EndTime = endTime
+	delete(m.sessions, key)
+	m.saveSession(key, session)
+}
+
+// saveSession writes a session to the history. Must be called with
+// m.historyMu held.
+func (m *Monitor) saveSession(key string, session *models.Session) {
+	if err := m.history.Save(session); err != nil {
+		m.logger.WithError(err).WithField("source", key).Warn("Failed to save live session")
+		return
+	}
+
+	if m.config.Verbose {
+		m.logger.WithFields(logrus.Fields{
+			"source":       key,
+			"session":      session.ID,
+			"open":         session.IsOpen(),
+			"peak_viewers": session.PeakViewers,
+		}).Debug("Live session saved")
+	}
+}
+
+// Sessions returns the recorded live sessions of a room that overlap the
+// range from to to, oldest first. A zero from or to leaves that end open.
+func (m *Monitor) Sessions(platform, roomID string, from, to time.Time) ([]models.Session, error) {
+	m.historyMu.Lock()
+	store := m.history
+	m.historyMu.Unlock()
+
+	if store == nil {
+		return nil, fmt.Errorf("session history is disabled, set history_file in the config")
+	}
+	return store.Sessions(platform, roomID, from, to)
+}
@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/history"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHistoryMonitor creates a monitor with a session history in a temporary
+// directory, watching the given fake source as fake:1
+func newHistoryMonitor(t *testing.T, path string, source *fakeSource) *Monitor {
+	t.Helper()
+
+	data, err := json.Marshal(Config{HistoryFile: path})
+	require.NoError(t, err)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configFile, data, 0644))
+
+	m, err := NewMonitor(configFile)
+	require.NoError(t, err)
+	m.sources = map[string]StreamSource{"fake:1": source}
+	t.Cleanup(m.closeHistory)
+	return m
+}
+
+func TestMonitor_RecordsSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	source := newFakeSource("1")
+	source.roomInfo.UName = "Alice"
+	source.roomInfo.Title = "Morning"
+	source.roomInfo.Online = 100
+	m := newHistoryMonitor(t, path, source)
+
+	live := sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}}
+	preparing := sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}}
+
+	m.handleSourceEvent(live)
+	sessions, err := m.Sessions("fake", "1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].IsOpen())
+	assert.Equal(t, "Alice", sessions[0].UName)
+	assert.Equal(t, "Morning", sessions[0].Title)
+
+	// Title changes and a new viewer peak are recorded while live
+	source.roomInfo.Title = "Evening"
+	source.roomInfo.Online = 300
+	m.handleSourceEvent(live)
+	source.roomInfo.Online = 200
+	m.handleSourceEvent(live)
+
+	m.handleSourceEvent(preparing)
+	sessions, err = m.Sessions("fake", "1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	session := sessions[0]
+	assert.False(t, session.IsOpen())
+	require.Len(t, session.TitleChanges, 1)
+	assert.Equal(t, "Evening", session.TitleChanges[0].Title)
+	assert.Equal(t, 300, session.PeakViewers)
+	assert.False(t, session.EndTime.Before(session.StartTime))
+
+	// The next live start opens a new session
+	m.handleSourceEvent(live)
+	sessions, err = m.Sessions("fake", "1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+}
+
+func TestMonitor_ResumesOpenSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := history.Open(path)
+	require.NoError(t, err)
+	start := time.Now().Add(-time.Hour)
+	require.NoError(t, store.Save(&models.Session{Platform: "fake", RoomID: "1", Title: "Before restart", StartTime: start}))
+	require.NoError(t, store.Close())
+
+	// The session left open by the previous run is continued while the room is live
+	source := newFakeSource("1")
+	source.roomInfo.Title = "Before restart"
+	m := newHistoryMonitor(t, path, source)
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}})
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}})
+
+	sessions, err := m.Sessions("fake", "1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].StartTime.Equal(start))
+	assert.False(t, sessions[0].IsOpen())
+}
+
+func TestMonitor_PushedSessionStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	source := newFakeSource("1")
+	platformStart := time.Now().Add(-time.Hour)
+	source.roomInfo.StartTime = platformStart
+	m := newHistoryMonitor(t, path, source)
+
+	live := sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}}
+	preparing := sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}}
+
+	// The first session starts when the platform says it did
+	m.handleSourceEvent(live)
+	m.handleSourceEvent(preparing)
+
+	// The source still reports the old start time when the next stream is
+	// pushed, so the new session starts when the room was seen going live
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	m.handleSourceEvent(live)
+
+	sessions, err := m.Sessions("fake", "1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.True(t, sessions[0].StartTime.Equal(platformStart))
+	assert.False(t, sessions[0].IsOpen())
+	assert.True(t, sessions[1].IsOpen())
+	assert.False(t, sessions[1].StartTime.Before(before))
+	assert.True(t, sessions[1].StartTime.After(sessions[0].EndTime))
+}
+
+func TestMonitor_SessionsWithoutHistory(t *testing.T) {
+	m := newTestMonitor(t, Config{}, nil)
+	_, err := m.Sessions("fake", "1", time.Time{}, time.Time{})
+	assert.ErrorContains(t, err, "session history is disabled")
+}
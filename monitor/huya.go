@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+// HuyaStreamSource implements StreamSource interface for Huya platform
+type HuyaStreamSource struct {
+	service    *service.HuyaService
+	roomInfo   models.RoomInfo
+	lastStatus bool
+	logger     *logrus.Entry
+}
+
+func init() {
+	RegisterPlatform(Platform{
+		Name: "huya",
+		Factory: func(room RoomConfig, config Config) (StreamSource, error) {
+			return NewHuyaStreamSourceWithConfig(room.RoomID, config.Platforms.Huya)
+		},
+		Validate: validateHuyaRoom,
+		Capabilities: Capabilities{
+			PlayURL: true,
+		},
+	})
+}
+
+// validateHuyaRoom checks the room fields a Huya source needs
+func validateHuyaRoom(room RoomConfig) error {
+	if room.RoomID == "" {
+		return fmt.Errorf("room_id is required")
+	}
+	if err := service.ValidateHuyaRoomID(room.RoomID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewHuyaStreamSource creates a new Huya stream source
+func NewHuyaStreamSource(roomID string) (*HuyaStreamSource, error) {
+	return NewHuyaStreamSourceWithConfig(roomID, HuyaConfig{})
+}
+
+// NewHuyaStreamSourceWithConfig creates a Huya stream source that uses the platform settings
+func NewHuyaStreamSourceWithConfig(roomID string, config HuyaConfig) (*HuyaStreamSource, error) {
+	svc, err := service.NewHuyaServiceWithConfig(roomID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HuyaStreamSource{
+		service: svc,
+		roomInfo: models.RoomInfo{
+			Platform: "huya",
+			RoomID:   roomID,
+		},
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "monitor",
+			"platform":  "huya",
+			"room_id":   roomID,
+		}),
+	}, nil
+}
+
+// GetStatus returns the current live status.
+// The same API call also refreshes the room metadata.
+func (h *HuyaStreamSource) GetStatus() (models.LiveStatus, error) {
+	info, err := h.service.GetHuyaRoomInfo()
+	if err != nil {
+		return models.StatusUnknown, fmt.Errorf("failed to get live status: %w", err)
+	}
+
+	// Update start time if status changed, the API value wins when present
+	if info.IsLive != h.lastStatus {
+		if info.IsLive {
+			h.roomInfo.StartTime = time.Now()
+		}
+		h.lastStatus = info.IsLive
+	}
+	h.applyRoomInfo(info)
+
+	if info.IsLive {
+		return models.StatusLive, nil
+	}
+	return models.StatusOffline, nil
+}
+
+// GetRoomInfo returns the room information
+func (h *HuyaStreamSource) GetRoomInfo() models.RoomInfo {
+	if h.roomInfo.RealRoomID == "" || h.roomInfo.UName == "" {
+		if info, err := h.service.GetHuyaRoomInfo(); err == nil {
+			h.applyRoomInfo(info)
+		} else {
+			h.logger.WithError(err).Error("Failed to get room info")
+
+			// Fallback: use default values to ensure notifications still work
+			if h.roomInfo.UName == "" {
+				h.roomInfo.UName = fmt.Sprintf("主播%s", h.roomInfo.RoomID)
+				h.logger.WithField("anchor_name", h.roomInfo.UName).Warn("Using default anchor name")
+			}
+		}
+	}
+
+	return h.roomInfo
+}
+
+// applyRoomInfo copies metadata from the API into the room info
+func (h *HuyaStreamSource) applyRoomInfo(info *service.HuyaRoomInfo) {
+	h.roomInfo.IsLive = info.IsLive
+	if info.RoomID != "" {
+		h.roomInfo.RealRoomID = info.RoomID
+	}
+	if info.UID != "" {
+		h.roomInfo.UID = info.UID
+	}
+	if info.Nick != "" {
+		h.roomInfo.UName = info.Nick
+	}
+	h.roomInfo.Title = info.Title
+	h.roomInfo.UserCover = info.Screenshot
+	h.roomInfo.Keyframe = info.Screenshot
+	if info.IsLive && !info.StartTime.IsZero() {
+		h.roomInfo.StartTime = info.StartTime
+	}
+}
+
+// GetPlayURL returns the live stream URL, preferring FLV over HLS
+func (h *HuyaStreamSource) GetPlayURL() string {
+	urls, err := h.service.GetHuyaLiveRealURL("flv")
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to get FLV URLs, trying HLS")
+		urls, err = h.service.GetHuyaLiveRealURL("hls")
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get live URLs")
+		return ""
+	}
+
+	return urls[0]
+}
+
+// StartMsgListener is a no-op, Huya room messages are not supported
+func (h *HuyaStreamSource) StartMsgListener() {
+	h.logger.WithField("room_id", h.roomInfo.RoomID).Debug("Room messages are not supported for Huya, using polling")
+}
+
+// CloseMsgListener is a no-op, Huya room messages are not supported
+func (h *HuyaStreamSource) CloseMsgListener() {}
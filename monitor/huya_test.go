@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHuyaTestSource returns a Huya source backed by a local server with a recorded response
+func newHuyaTestSource(t *testing.T, roomResponse string) *HuyaStreamSource {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(roomResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewHuyaStreamSource("660000")
+	require.NoError(t, err)
+	source.service.Client.SetBaseURL(server.URL).SetRetryCount(0)
+	return source
+}
+
+func TestNewHuyaStreamSource(t *testing.T) {
+	source, err := NewHuyaStreamSource("660000")
+	require.NoError(t, err)
+	assert.Equal(t, "huya", source.roomInfo.Platform)
+	assert.Equal(t, "660000", source.roomInfo.RoomID)
+
+	_, err = NewHuyaStreamSource("bad/id")
+	assert.Error(t, err)
+
+	p, ok := LookupPlatform("huya")
+	require.True(t, ok)
+	assert.True(t, p.Capabilities.PlayURL)
+}
+
+func TestHuyaStreamSource_LiveRoom(t *testing.T) {
+	antiCode := "wsSecret=x&wsTime=6553f000&fm=RFdxOEJjSjNoNkRKdDZUWV8kMF8kMV8kMl8kMw%3D%3D&ctype=huya_live&fs=bgct"
+	source := newHuyaTestSource(t, `{"status":200,"message":"","data":{"liveStatus":"ON",`+
+		`"profileInfo":{"uid":1199512345678,"nick":"虎牙主播"},`+
+		`"liveData":{"profileRoom":660000,"introduction":"周末开黑","screenshot":"https://live-cover.msstatic.com/cover.jpg","startTime":1709294400},`+
+		`"stream":{"baseSteamInfoList":[{"sCdnType":"AL","sStreamName":"1234-abc","sFlvUrl":"https://al.flv.huya.com/src","sFlvUrlSuffix":"flv","sFlvAntiCode":"`+antiCode+`"}]}}}`)
+
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	// Room info is filled the same way as for Bilibili so notifications work unchanged
+	info := source.GetRoomInfo()
+	assert.Equal(t, "huya", info.Platform)
+	assert.Equal(t, "660000", info.RealRoomID)
+	assert.Equal(t, "1199512345678", info.UID)
+	assert.Equal(t, "虎牙主播", info.UName)
+	assert.Equal(t, "周末开黑", info.Title)
+	assert.Equal(t, "https://live-cover.msstatic.com/cover.jpg", info.UserCover)
+	assert.Equal(t, int64(1709294400), info.StartTime.Unix())
+	assert.True(t, info.IsLive)
+
+	playURL := source.GetPlayURL()
+	assert.True(t, strings.HasPrefix(playURL, "https://al.flv.huya.com/src/1234-abc.flv?"), playURL)
+}
+
+func TestHuyaStreamSource_Offline(t *testing.T) {
+	source := newHuyaTestSource(t, `{"status":200,"message":"","data":{"liveStatus":"OFF","profileInfo":{"uid":1,"nick":"虎牙主播"},"liveData":{"profileRoom":660000}}}`)
+
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusOffline, status)
+	assert.Empty(t, source.GetPlayURL())
+}
+
+func TestHuyaStreamSource_Errors(t *testing.T) {
+	source := newHuyaTestSource(t, `{"status":422,"message":"该主播不存在！","data":""}`)
+
+	status, err := source.GetStatus()
+	assert.Error(t, err)
+	assert.Equal(t, models.StatusUnknown, status)
+	assert.Equal(t, "主播660000", source.GetRoomInfo().UName)
+}
@@ -3,12 +3,18 @@ package monitor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/nick3/restreamer_monitor_go/history"
 	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
 	"github.com/nick3/restreamer_monitor_go/notification"
+	"github.com/nick3/restreamer_monitor_go/service"
 	"github.com/nick3/restreamer_monitor_go/telegram"
 	"github.com/sirupsen/logrus"
 )
@@ -19,6 +25,16 @@ type Config struct {
 	Relays   []RelayConfig `json:"relays,omitempty"`
 	Telegram TelegramConfig `json:"telegram,omitempty"`
 	Interval string        `json:"interval"`
+	ReconcileInterval string `json:"reconcile_interval,omitempty"` // Polling interval for rooms with a message connection
+	MaxConcurrentChecks int  `json:"max_concurrent_checks,omitempty"` // Number of rooms checked in parallel (default 8)
+	CheckTimeout string      `json:"check_timeout,omitempty"`          // Deadline for a single room check (default 20s)
+	Platforms PlatformsConfig `json:"platforms,omitempty"`
+	FollowSync *FollowSyncConfig `json:"follow_sync,omitempty"` // Imports rooms from a Bilibili account's follow list
+	HistoryFile string `json:"history_file,omitempty"` // Database file for live session history, disabled if empty
+	StateFile   string `json:"state_file,omitempty"`   // Keeps room status across restarts, disabled if empty
+	// CatchUpSummary sends one summary of the changes found by the first
+	// check after a restart instead of a notification per room
+	CatchUpSummary bool `json:"catch_up_summary,omitempty"`
 	Verbose  bool          `json:"verbose"`
 	Logger   LoggerConfig  `json:"logger"`
 }
@@ -67,6 +83,19 @@ type RoomConfig struct {
 	Platform string `json:"platform"`
 	RoomID   string `json:"room_id"`
 	Enabled  bool   `json:"enabled"`
+	// OfflineConfirmations is the number of consecutive offline observations
+	// needed before a live end is confirmed (default 1)
+	OfflineConfirmations int `json:"offline_confirmations,omitempty"`
+	// OfflineGracePeriod is how long a room must stay offline before a live
+	// end is confirmed, e.g. "2m" (default 0)
+	OfflineGracePeriod string `json:"offline_grace_period,omitempty"`
+	// NotifyRotation sends notifications when rotation playback starts and ends
+	NotifyRotation bool `json:"notify_rotation,omitempty"`
+	// URL and the metadata below are used by platforms without an API, such as "url"
+	URL   string `json:"url,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Title string `json:"title,omitempty"`
+	Cover string `json:"cover,omitempty"`
 }
 
 // RelayConfig represents a relay configuration for streaming
@@ -76,12 +105,21 @@ type RelayConfig struct {
 	Destinations []Destination `json:"destinations"`
 	Enabled      bool   `json:"enabled"`
 	Quality      string `json:"quality,omitempty"` // e.g., "best", "worst", "720p"
+	Codec        string `json:"codec,omitempty"`   // Preferred source codec, "avc" or "hevc"
+	Proxy        string `json:"proxy,omitempty"`   // HTTP proxy for stream pulls, overrides the platform proxy
+	SkipRotation bool   `json:"skip_rotation,omitempty"` // Do not relay rotation playback, only real live streams
 }
 
 // Source represents the source stream configuration
 type Source struct {
 	Platform string `json:"platform"`
 	RoomID   string `json:"room_id"`
+	URL      string `json:"url,omitempty"` // Stream URL for the "url" platform
+}
+
+// RoomConfig returns the source as an enabled room configuration
+func (s Source) RoomConfig() RoomConfig {
+	return RoomConfig{Platform: s.Platform, RoomID: s.RoomID, Enabled: true, URL: s.URL}
 }
 
 // Destination represents the destination stream configuration
@@ -92,6 +130,47 @@ type Destination struct {
 	Options  map[string]string `json:"options,omitempty"`
 }
 
+// PlatformsConfig holds platform-wide settings shared by all rooms of a platform
+type PlatformsConfig struct {
+	Bilibili BilibiliConfig `json:"bilibili,omitempty"`
+	Douyu    DouyuConfig    `json:"douyu,omitempty"`
+	Huya     HuyaConfig     `json:"huya,omitempty"`
+	Twitch   TwitchConfig   `json:"twitch,omitempty"`
+	URL      URLConfig      `json:"url,omitempty"`
+}
+
+// Proxy returns the proxy configured for a platform, empty if none
+func (p PlatformsConfig) Proxy(platform string) string {
+	switch platform {
+	case "bilibili":
+		return p.Bilibili.Proxy
+	case "douyu":
+		return p.Douyu.Proxy
+	case "huya":
+		return p.Huya.Proxy
+	case "twitch":
+		return p.Twitch.Proxy
+	case "url":
+		return p.URL.Proxy
+	}
+	return ""
+}
+
+// BilibiliConfig is a type alias for service.BilibiliConfig
+type BilibiliConfig = service.BilibiliConfig
+
+// DouyuConfig is a type alias for service.DouyuConfig
+type DouyuConfig = service.DouyuConfig
+
+// HuyaConfig is a type alias for service.HuyaConfig
+type HuyaConfig = service.HuyaConfig
+
+// TwitchConfig is a type alias for service.TwitchConfig
+type TwitchConfig = service.TwitchConfig
+
+// URLConfig is a type alias for service.URLConfig
+type URLConfig = service.URLConfig
+
 // LoggerConfig is a type alias for logger.Config
 type LoggerConfig = logger.Config
 
@@ -99,13 +178,62 @@ type LoggerConfig = logger.Config
 type Monitor struct {
 	config            Config
 	sources           map[string]StreamSource
+	rooms             map[string]RoomConfig
 	notificationMgr   *notification.NotificationManager
 	ctx               context.Context
 	cancel            context.CancelFunc
-	lastStatus        map[string]bool // Track last status for notifications
+	mu                sync.RWMutex
+	lastStatus        map[string]models.LiveStatus // Track last status for notifications
+	failures          map[string]int               // Consecutive failed status checks per room
+	pendingOffline    map[string]*pendingOffline   // Live rooms seen offline but not yet confirmed
+	sessionStart      map[string]time.Time         // Start of the current live session per room
+	riskControlled    map[string]bool              // Rooms whose last check was blocked by risk control
+	lastChecked       map[string]time.Time // Only touched by the running check round
+	pushActive        map[string]bool      // Only touched by the running check round
+	sourceLocks       map[string]*sync.Mutex // Serializes calls into each source
+	followed          map[string]service.BilibiliFollowedRoom // Rooms imported by follow sync, only touched by the sync
+	history           *history.Store             // Session history, nil if disabled
+	sessions          map[string]*models.Session // Open live session per room
+	historyMu         sync.Mutex                 // Guards history and sessions
+	lastNotices       map[string]sentNotice      // Last notification sent per room
+	restored          map[string]bool            // Rooms restored from the state file but not checked yet
+	catchUp           []models.StatusChange      // Changes of restored rooms for the catch-up summary
+	catchUpOnce       sync.Once
+	stateMu           sync.Mutex // Serializes state file writes
+	stateSavedAt      time.Time
+	unresolved        []RoomConfig           // Configured rooms to resolve again, only used by check rounds
+	checking          atomic.Bool            // Set while a check round is running
+	rounds            sync.WaitGroup
+	overruns          atomic.Int64
 	logger            *logrus.Entry
 }
 
+const (
+	defaultMaxConcurrentChecks = 8
+	defaultCheckTimeout        = 20 * time.Second
+	// roomResolveTimeout bounds the room lookups made at startup
+	roomResolveTimeout = 15 * time.Second
+)
+
+// statusResult is the outcome of a single GetStatus call
+type statusResult struct {
+	status models.LiveStatus
+	err    error
+}
+
+// pendingOffline tracks a live room that has been observed offline
+// but has not yet met its confirmation thresholds
+type pendingOffline struct {
+	since        time.Time
+	observations int
+}
+
+// sourceEvent is a room message tagged with the key of the source it came from
+type sourceEvent struct {
+	key   string
+	event service.DanmakuEvent
+}
+
 // NewMonitor creates a new monitor instance
 func NewMonitor(configFile string) (*Monitor, error) {
 	config, err := loadConfig(configFile)
@@ -118,40 +246,75 @@ func NewMonitor(configFile string) (*Monitor, error) {
 	monitor := &Monitor{
 		config:     config,
 		sources:    make(map[string]StreamSource),
+		rooms:      make(map[string]RoomConfig),
 		ctx:        ctx,
 		cancel:     cancel,
-		lastStatus: make(map[string]bool),
+		lastStatus: make(map[string]models.LiveStatus),
+		failures:    make(map[string]int),
+		pendingOffline: make(map[string]*pendingOffline),
+		sessionStart:   make(map[string]time.Time),
+		riskControlled: make(map[string]bool),
+		lastChecked: make(map[string]time.Time),
+		pushActive:  make(map[string]bool),
+		sourceLocks: make(map[string]*sync.Mutex),
+		followed:    make(map[string]service.BilibiliFollowedRoom),
+		sessions:    make(map[string]*models.Session),
+		lastNotices: make(map[string]sentNotice),
+		restored:    make(map[string]bool),
 		logger:     logger.GetLogger(map[string]interface{}{"component": "monitor", "module": "main"}),
 	}
 
-	// Initialize stream sources
-	for _, room := range config.Rooms {
-		if !room.Enabled {
-			continue
-		}
+	// The shared client is configured once, before any source uses it
+	if err := service.DefaultBilibiliClient().Configure(config.Platforms.Bilibili); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to configure Bilibili client: %w", err)
+	}
 
-		var source StreamSource
-		var err error
+	if config.StateFile != "" {
+		monitor.restoreState()
+	}
 
-		switch room.Platform {
-		case "bilibili":
-			source, err = NewBilibiliStreamSource(room.RoomID)
-		default:
-			monitor.logger.Warnf("Unsupported platform: %s", room.Platform)
-			continue
+	if config.HistoryFile != "" {
+		if err := monitor.openHistory(config.HistoryFile); err != nil {
+			cancel()
+			return nil, err
 		}
+	}
 
-		if err != nil {
-			monitor.logger.WithError(err).Errorf("Failed to create source for room %s", room.RoomID)
-			continue
+	// Resolve room references such as URLs to canonical room IDs
+	var enabled []RoomConfig
+	for _, room := range config.Rooms {
+		if room.Enabled {
+			enabled = append(enabled, room)
 		}
-
-		key := fmt.Sprintf("%s:%s", room.Platform, room.RoomID)
-		monitor.sources[key] = source
 	}
+	resolveCtx, cancelResolve := context.WithTimeout(ctx, roomResolveTimeout)
+	rooms, unresolved, err := ResolveRooms(resolveCtx, enabled, config)
+	cancelResolve()
+	if err != nil {
+		monitor.logger.WithError(err).Error("Failed to resolve some rooms, retrying them every check round")
+	}
+	monitor.unresolved = unresolved
+
+	// Initialize stream sources
+	monitor.addRooms(rooms, false)
 
 	// Initialize notification manager
-	notificationConfig := notification.Config{
+	notificationMgr, err := NewNotificationManager(config)
+	if err != nil {
+		monitor.logger.WithError(err).Warn("Failed to create notification manager, continuing without notifications")
+		// Continue without notifications
+	} else {
+		monitor.notificationMgr = notificationMgr
+	}
+
+	return monitor, nil
+}
+
+// NewNotificationManager creates a notification manager from the Telegram
+// settings of config
+func NewNotificationManager(config Config) (*notification.NotificationManager, error) {
+	return notification.NewNotificationManager(notification.Config{
 		Telegram: telegram.Config{
 			BotToken:        config.Telegram.BotToken,
 			ChatIDs:         config.Telegram.ChatIDs,
@@ -165,16 +328,7 @@ func NewMonitor(configFile string) (*Monitor, error) {
 			RelayEvents:   config.Telegram.Notifications.RelayEvents,
 			ErrorEvents:   config.Telegram.Notifications.ErrorEvents,
 		},
-	}
-	notificationMgr, err := notification.NewNotificationManager(notificationConfig)
-	if err != nil {
-		monitor.logger.WithError(err).Warn("Failed to create notification manager, continuing without notifications")
-		// Continue without notifications
-	} else {
-		monitor.notificationMgr = notificationMgr
-	}
-
-	return monitor, nil
+	})
 }
 
 // loadConfig loads configuration from JSON file
@@ -183,6 +337,7 @@ func loadConfig(configFile string) (Config, error) {
 
 	// Set default values
 	config.Interval = "30s"
+	config.ReconcileInterval = "5m"
 	config.Verbose = false
 	config.Logger = logger.DefaultConfig()
 
@@ -204,12 +359,18 @@ func loadConfig(configFile string) (Config, error) {
 		return config, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return config, nil
 }
 
 // Run starts the monitoring process
 func (m *Monitor) Run() error {
-	if len(m.sources) == 0 {
+	// With follow sync the rooms may all come from the follow list, and
+	// rooms that could not be resolved yet are retried every round
+	if len(m.sources) == 0 && len(m.unresolved) == 0 && m.config.FollowSync == nil {
 		return fmt.Errorf("no valid stream sources configured")
 	}
 
@@ -220,6 +381,9 @@ func (m *Monitor) Run() error {
 	}
 
 	m.logger.Infof("Starting monitor with %d sources, checking every %v", len(m.sources), interval)
+	if reconcile := m.reconcileInterval(); reconcile > interval {
+		m.logger.Infof("Rooms with a message connection are reconciled every %v", reconcile)
+	}
 
 	// Start notification manager if available
 	if m.notificationMgr != nil {
@@ -228,12 +392,21 @@ func (m *Monitor) Run() error {
 		}
 	}
 
+	if m.config.FollowSync != nil {
+		m.syncFollows(service.DefaultBilibiliClient(), false)
+	}
+
+	if bilibili := m.config.Platforms.Bilibili; bilibili.HasLogin() && m.hasPlatform("bilibili") {
+		go m.watchBilibiliSession(service.DefaultBilibiliClient())
+	}
+
 	// Start message listeners
 	for key, source := range m.sources {
-		if m.config.Verbose {
-			m.logger.Debugf("Starting message listener for %s", key)
-		}
-		source.StartMsgListener()
+		m.startMsgListener(key, source)
+	}
+
+	if followSync := m.config.FollowSync; followSync != nil {
+		go m.watchFollows(service.DefaultBilibiliClient(), followSync.SyncInterval())
 	}
 
 	// Main monitoring loop
@@ -244,14 +417,110 @@ func (m *Monitor) Run() error {
 		select {
 		case <-m.ctx.Done():
 			m.logger.Info("Monitor stopping...")
+			m.rounds.Wait()
 			m.cleanup()
 			return nil
 		case <-ticker.C:
-			m.checkAllSources()
+			m.startCheckRound(interval)
 		}
 	}
 }
 
+// startMsgListener starts a source's message listener and applies its messages
+func (m *Monitor) startMsgListener(key string, source StreamSource) {
+	if m.config.Verbose {
+		m.logger.Debugf("Starting message listener for %s", key)
+	}
+	source.StartMsgListener()
+
+	if ms, ok := source.(MessageSource); ok {
+		go m.forwardEvents(key, ms.Events())
+	}
+}
+
+// startCheckRound checks all sources in the background so that a slow round
+// never blocks the loop. A tick that finds the previous round still running
+// is counted as an overrun and skipped.
+func (m *Monitor) startCheckRound(interval time.Duration) {
+	if !m.checking.CompareAndSwap(false, true) {
+		overruns := m.overruns.Add(1)
+		m.logger.WithFields(logrus.Fields{
+			"interval": interval,
+			"overruns": overruns,
+		}).Warn("Previous status check round still running, skipping tick")
+		return
+	}
+
+	m.rounds.Add(1)
+	go func() {
+		defer m.rounds.Done()
+		defer m.checking.Store(false)
+
+		start := time.Now()
+		m.resolvePendingRooms()
+		m.checkAllSources()
+		if m.config.CatchUpSummary {
+			m.catchUpOnce.Do(m.flushCatchUp)
+		}
+		if elapsed := time.Since(start); elapsed > interval {
+			m.logger.WithFields(logrus.Fields{
+				"elapsed":  elapsed.Round(time.Millisecond),
+				"interval": interval,
+			}).Warn("Status check round took longer than the check interval")
+		}
+	}()
+}
+
+// addRooms creates sources for resolved rooms. A room that is already
+// monitored, e.g. a short ID and the real ID of the same room, is merged.
+func (m *Monitor) addRooms(rooms []RoomConfig, startListeners bool) {
+	for _, room := range rooms {
+		key := fmt.Sprintf("%s:%s", room.Platform, room.RoomID)
+		m.mu.RLock()
+		_, exists := m.sources[key]
+		m.mu.RUnlock()
+		if exists {
+			m.logger.WithField("source", key).Warn("Room is configured more than once, merging")
+			continue
+		}
+
+		source, err := NewStreamSource(room, m.config)
+		if err != nil {
+			m.logger.WithError(err).Errorf("Failed to create source for room %s", room.RoomID)
+			continue
+		}
+
+		m.mu.Lock()
+		m.sources[key] = source
+		m.rooms[key] = room
+		m.mu.Unlock()
+		if startListeners {
+			m.startMsgListener(key, source)
+		}
+	}
+}
+
+// resolvePendingRooms retries the configured rooms that could not be
+// resolved so far, e.g. because the lookup failed at startup, and starts
+// monitoring the ones that resolve
+func (m *Monitor) resolvePendingRooms() {
+	if len(m.unresolved) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, roomResolveTimeout)
+	rooms, unresolved, err := ResolveRooms(ctx, m.unresolved, m.config)
+	cancel()
+	m.unresolved = unresolved
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to resolve some rooms, retrying next round")
+	}
+	if len(rooms) > 0 {
+		m.logger.Infof("Resolved %d rooms, starting to monitor them", len(rooms))
+		m.addRooms(rooms, true)
+	}
+}
+
 // Stop stops the monitoring process
 func (m *Monitor) Stop() {
 	if m.cancel != nil {
@@ -269,10 +538,261 @@ func (m *Monitor) GetConfig() Config {
 	return m.config
 }
 
+// Status returns the last known live status of a source and whether it has been checked.
+// Rooms blocked by the platform's risk control report StatusUnknown.
+func (m *Monitor) Status(key string) (models.LiveStatus, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.riskControlled[key] {
+		return models.StatusUnknown, true
+	}
+	status, exists := m.lastStatus[key]
+	return status, exists
+}
+
+// TickOverruns returns how many ticks were skipped because the previous
+// check round was still running
+func (m *Monitor) TickOverruns() int64 {
+	return m.overruns.Load()
+}
+
+// ConsecutiveFailures returns how many status checks in a row failed for a source
+func (m *Monitor) ConsecutiveFailures(key string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.failures[key]
+}
+
+// reconcileInterval returns the slow polling interval for push-capable rooms
+func (m *Monitor) reconcileInterval() time.Duration {
+	reconcile, err := time.ParseDuration(m.config.ReconcileInterval)
+	if err != nil || reconcile <= 0 {
+		return 5 * time.Minute
+	}
+	return reconcile
+}
+
+// maxConcurrentChecks returns the size of the check worker pool
+func (m *Monitor) maxConcurrentChecks() int {
+	if m.config.MaxConcurrentChecks <= 0 {
+		return defaultMaxConcurrentChecks
+	}
+	return m.config.MaxConcurrentChecks
+}
+
+// StatusCheckTimeout returns the deadline for a single status check
+func (c Config) StatusCheckTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.CheckTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultCheckTimeout
+	}
+	return timeout
+}
+
+// checkTimeout returns the deadline for a single room check
+func (m *Monitor) checkTimeout() time.Duration {
+	return m.config.StatusCheckTimeout()
+}
+
+// sourceLock returns the mutex that serializes calls into a source
+func (m *Monitor) sourceLock(key string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, exists := m.sourceLocks[key]
+	if !exists {
+		lock = &sync.Mutex{}
+		m.sourceLocks[key] = lock
+	}
+	return lock
+}
+
+// forwardEvents applies a source's room messages in order. Each source has
+// its own forwarder, so a busy room never delays messages for the others.
+func (m *Monitor) forwardEvents(key string, events <-chan service.DanmakuEvent) {
+	if events == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			m.handleSourceEvent(sourceEvent{key: key, event: event})
+		}
+	}
+}
+
+// handleSourceEvent applies pushed LIVE/PREPARING messages immediately
+func (m *Monitor) handleSourceEvent(ev sourceEvent) {
+	m.mu.RLock()
+	source, exists := m.sources[ev.key]
+	m.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	lock := m.sourceLock(ev.key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if es, ok := source.(EventSource); ok {
+		es.ApplyEvent(ev.event)
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.checkTimeout())
+	defer cancel()
+
+	switch ev.event.Type {
+	case service.DanmakuEventLive:
+		m.logger.WithField("source", ev.key).Info("Received live start message")
+		m.updateStatus(ctx, ev.key, source, models.StatusLive)
+	case service.DanmakuEventPreparing:
+		if ev.event.Rotation {
+			m.logger.WithField("source", ev.key).Info("Received rotation start message")
+			m.updateStatus(ctx, ev.key, source, models.StatusRotation)
+			return
+		}
+		m.logger.WithField("source", ev.key).Info("Received live end message")
+		m.updateStatus(ctx, ev.key, source, models.StatusOffline)
+	default:
+		if m.config.Verbose {
+			m.logger.WithFields(logrus.Fields{
+				"source": ev.key,
+				"type":   ev.event.Type,
+			}).Debug("Received room message")
+		}
+	}
+}
+
+// pollDue reports whether a source should be polled on this tick.
+// Rooms with a working message connection are only reconciled occasionally;
+// all other rooms are polled on every tick.
+func (m *Monitor) pollDue(key string, source StreamSource, now time.Time) bool {
+	ms, ok := source.(MessageSource)
+	connected := ok && ms.MsgConnected()
+
+	if connected != m.pushActive[key] {
+		if connected {
+			m.logger.WithField("source", key).Info("Message connection active, switching to reconciliation polling")
+		} else if _, seen := m.pushActive[key]; seen {
+			m.logger.WithField("source", key).Warn("Message connection lost, falling back to fast polling")
+		}
+	}
+	m.pushActive[key] = connected
+
+	// Rooms waiting for offline confirmation need fresh observations
+	m.mu.RLock()
+	_, pending := m.pendingOffline[key]
+	m.mu.RUnlock()
+
+	if !connected || pending {
+		return true
+	}
+
+	lastChecked, checked := m.lastChecked[key]
+	return !checked || now.Sub(lastChecked) >= m.reconcileInterval()
+}
+
 // checkAllSources checks the status of all configured sources
 func (m *Monitor) checkAllSources() {
+	now := time.Now()
+	due := make(map[string]StreamSource)
+	for key, source := range m.monitoredSources() {
+		if m.pollDue(key, source, now) {
+			due[key] = source
+		}
+	}
+
+	m.prefetchStatuses(due)
+
+	workers := m.maxConcurrentChecks()
+	if workers > len(due) {
+		workers = len(due)
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				m.checkSource(key, due[key])
+			}
+		}()
+	}
+
+dispatch:
+	for key := range due {
+		// Stop handing out checks once the monitor is stopping
+		select {
+		case <-m.ctx.Done():
+			break dispatch
+		default:
+		}
+
+		if m.config.Verbose {
+			m.logger.Debugf("Checking status for %s", key)
+		}
+
+		m.lastChecked[key] = time.Now()
+		select {
+		case jobs <- key:
+		case <-m.ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if m.config.Verbose {
+		stats := service.DefaultBilibiliClient().Stats()
+		m.logger.WithFields(logrus.Fields{
+			"cache_hits":   stats.Hits,
+			"cache_misses": stats.Misses,
+			"coalesced":    stats.Coalesced,
+		}).Debug("Bilibili API request statistics")
+	}
+}
+
+// monitoredSources returns a snapshot of the sources, which follow sync may
+// change while a round is running
+func (m *Monitor) monitoredSources() map[string]StreamSource {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := make(map[string]StreamSource, len(m.sources))
 	for key, source := range m.sources {
-		// Check if context is cancelled before processing each source
+		sources[key] = source
+	}
+	return sources
+}
+
+// prefetchStatuses refreshes the batch results of all due sources that
+// share a StatusBatcher. Rooms missing from a failed batch fall back to
+// per-room requests in their own GetStatus.
+func (m *Monitor) prefetchStatuses(due map[string]StreamSource) {
+	groups := make(map[StatusBatcher][]string)
+	for _, source := range due {
+		bs, ok := source.(BatchedSource)
+		if !ok {
+			continue
+		}
+		batcher, roomID := bs.Batcher()
+		if batcher == nil {
+			continue
+		}
+		groups[batcher] = append(groups[batcher], roomID)
+	}
+
+	for batcher, roomIDs := range groups {
 		select {
 		case <-m.ctx.Done():
 			return
@@ -280,59 +800,333 @@ func (m *Monitor) checkAllSources() {
 		}
 
 		if m.config.Verbose {
-			m.logger.Debugf("Checking status for %s", key)
+			m.logger.Debugf("Prefetching status for %d rooms", len(roomIDs))
+		}
+		if err := batcher.Refresh(m.ctx, roomIDs); err != nil {
+			m.logger.WithError(err).WithField("rooms", len(roomIDs)).Warn("Batch status refresh failed, falling back to per-room checks")
 		}
+	}
+}
 
-		status := source.GetStatus()
-		roomInfo := source.GetRoomInfo()
+// checkSource polls a single source within the check timeout and applies
+// the result. Failed or timed out checks are counted but never change the
+// recorded status. A source whose previous check has not returned yet is skipped.
+func (m *Monitor) checkSource(key string, source StreamSource) {
+	lock := m.sourceLock(key)
+	if !lock.TryLock() {
+		m.logger.WithField("source", key).Warn("Previous status check still running, skipping room")
+		return
+	}
 
-		// Check if status changed
-		lastStatus, exists := m.lastStatus[key]
-		if !exists || status != lastStatus {
-			// Status changed, record end time if going from live to offline
-			if exists && lastStatus && !status {
-				// From live to offline, record the end time
-				roomInfo.EndTime = time.Now()
-			}
+	timeout := m.checkTimeout()
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	defer cancel()
 
-			// Status changed, send notification
-			if m.notificationMgr != nil {
-				m.notificationMgr.SendLiveStatusNotification(roomInfo.RoomID, roomInfo.Platform, status, roomInfo)
-			}
-			m.lastStatus[key] = status
+	results := make(chan statusResult, 1)
+	go func() {
+		status, err := StatusContext(ctx, source)
+		results <- statusResult{status: status, err: err}
+	}()
+
+	var status models.LiveStatus
+	var err error
+	select {
+	case result := <-results:
+		defer lock.Unlock()
+		status, err = result.status, result.err
+	case <-ctx.Done():
+		// The source stays locked until the abandoned call returns
+		go func() {
+			<-results
+			lock.Unlock()
+		}()
+		if m.ctx.Err() != nil {
+			return
 		}
+		status, err = models.StatusUnknown, fmt.Errorf("status check timed out after %v", timeout)
+	}
 
-		if m.config.Verbose || status {
-			statusStr := "offline"
-			if status {
-				statusStr = "live"
+	if err != nil || status == models.StatusUnknown {
+		riskControlled := errors.Is(err, service.ErrRiskControl)
+
+		m.mu.Lock()
+		if _, monitored := m.sources[key]; !monitored {
+			// The room was removed while it was being checked
+			m.mu.Unlock()
+			return
+		}
+		m.failures[key]++
+		failures := m.failures[key]
+		if riskControlled {
+			m.riskControlled[key] = true
+		}
+		m.mu.Unlock()
+
+		entry := m.logger.WithError(err).WithFields(logrus.Fields{
+			"source":               key,
+			"consecutive_failures": failures,
+		})
+		if riskControlled {
+			entry.Warn("Room check blocked by risk control, reporting status as unknown")
+		} else {
+			entry.Warn("Failed to determine room status, keeping last known state")
+		}
+		return
+	}
+
+	m.mu.Lock()
+	if _, monitored := m.sources[key]; !monitored {
+		m.mu.Unlock()
+		return
+	}
+	failures := m.failures[key]
+	m.failures[key] = 0
+	delete(m.riskControlled, key)
+	m.mu.Unlock()
+
+	if failures > 0 {
+		m.logger.WithFields(logrus.Fields{
+			"source":          key,
+			"failed_attempts": failures,
+		}).Info("Room status check recovered")
+	}
+
+	m.updateStatus(ctx, key, source, status)
+}
+
+// offlineThresholds returns the debounce settings for a room
+func (m *Monitor) offlineThresholds(key string) (int, time.Duration) {
+	room := m.rooms[key]
+
+	confirmations := room.OfflineConfirmations
+	if confirmations < 1 {
+		confirmations = 1
+	}
+
+	var grace time.Duration
+	if room.OfflineGracePeriod != "" {
+		var err error
+		grace, err = time.ParseDuration(room.OfflineGracePeriod)
+		if err != nil {
+			m.logger.WithError(err).WithField("source", key).Warnf("Invalid offline grace period %s, ignoring", room.OfflineGracePeriod)
+			grace = 0
+		}
+	}
+
+	return confirmations, grace
+}
+
+// confirmOffline records an offline observation for a live room and reports
+// whether the live end is confirmed, along with when the room was first seen offline.
+// Must be called with m.mu held.
+func (m *Monitor) confirmOffline(key string, now time.Time) (bool, time.Time) {
+	confirmations, grace := m.offlineThresholds(key)
+
+	pending, exists := m.pendingOffline[key]
+	if !exists {
+		pending = &pendingOffline{since: now}
+		m.pendingOffline[key] = pending
+	}
+	pending.observations++
+
+	if pending.observations >= confirmations && now.Sub(pending.since) >= grace {
+		delete(m.pendingOffline, key)
+		return true, pending.since
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"source":       key,
+		"observations": pending.observations,
+		"required":     confirmations,
+		"offline_for":  now.Sub(pending.since).Round(time.Second),
+		"grace_period": grace,
+	}).Info("Room appears offline, waiting for confirmation")
+	return false, pending.since
+}
+
+// updateStatus records a room's status and notifies on changes.
+// It is shared by polling and pushed room messages; ctx bounds the room
+// info and play URL requests.
+func (m *Monitor) updateStatus(ctx context.Context, key string, source StreamSource, status models.LiveStatus) {
+	if status == models.StatusUnknown {
+		return
+	}
+
+	now := time.Now()
+	isLive := status == models.StatusLive
+
+	m.mu.Lock()
+	if _, monitored := m.sources[key]; !monitored {
+		// The room was removed while its check was running
+		m.mu.Unlock()
+		return
+	}
+	lastStatus, exists := m.lastStatus[key]
+	lastNotice := m.lastNotices[key]
+	notifyRotation := m.rooms[key].NotifyRotation
+	endTime := now
+
+	// Leaving live is debounced so short drops do not end the session
+	if exists && lastStatus == models.StatusLive && !isLive {
+		confirmed, since := m.confirmOffline(key, now)
+		if !confirmed {
+			m.mu.Unlock()
+			return
+		}
+		endTime = since
+	}
+
+	// A quick return resumes the current session without a new notification
+	if pending, wasPending := m.pendingOffline[key]; wasPending && isLive {
+		delete(m.pendingOffline, key)
+		m.logger.WithFields(logrus.Fields{
+			"source":        key,
+			"offline_for":   now.Sub(pending.since).Round(time.Second),
+			"observations":  pending.observations,
+			"session_start": m.sessionStart[key],
+		}).Info("Suppressed live status flap, resuming session")
+	}
+
+	changed := !exists || status != lastStatus
+	restored := m.restored[key]
+	delete(m.restored, key)
+	m.lastStatus[key] = status
+	if changed {
+		if lastNotice.restored {
+			// Only the first change after a restart can repeat the last run's notice
+			m.lastNotices[key] = sentNotice{notice: lastNotice.notice, at: lastNotice.at}
+		}
+		if isLive {
+			m.sessionStart[key] = now
+		} else {
+			delete(m.sessionStart, key)
+		}
+	}
+	liveSince := m.sessionStart[key]
+	m.mu.Unlock()
+
+	roomInfo := RoomInfoContext(ctx, source)
+	roomInfo.IsLive = isLive
+	m.recordSession(key, roomInfo, liveSince, endTime)
+
+	if changed {
+		// Status changed, record end time if going from live to offline
+		if exists && lastStatus == models.StatusLive && !isLive {
+			// From live to offline, record when the room was first seen offline
+			roomInfo.EndTime = endTime
+		}
+
+		// Status changed, send notifications. Changes that happened while
+		// the monitor was stopped go into the catch-up summary if enabled.
+		if restored {
+			m.queueCatchUp(models.StatusChange{RoomInfo: roomInfo, From: lastStatus, To: status})
+		} else if m.notificationMgr != nil {
+			for _, notice := range statusNotices(lastStatus, exists, status, notifyRotation) {
+				// The saved status can be newer than the saved notice when
+				// the last run stopped between a change and its notification
+				if lastNotice.restored && notice == lastNotice.notice {
+					m.logger.WithFields(logrus.Fields{
+						"source": key,
+						"notice": notice.String(),
+					}).Info("Skipped notification already sent before the restart")
+					continue
+				}
+				switch notice {
+				case noticeLiveStart, noticeLiveEnd:
+					m.notificationMgr.SendLiveStatusNotification(roomInfo.RoomID, roomInfo.Platform, isLive, roomInfo)
+				case noticeRotationStart, noticeRotationEnd:
+					m.notificationMgr.SendRotationNotification(roomInfo.RoomID, roomInfo.Platform, status == models.StatusRotation, roomInfo)
+				}
+				m.recordNotice(key, notice)
 			}
+		}
+		m.persistState()
+	}
+
+	if m.config.Verbose || isLive {
+		m.logger.WithFields(logrus.Fields{
+			"room_id":  roomInfo.RoomID,
+			"platform": roomInfo.Platform,
+			"status":   status.String(),
+		}).Info("Room status update")
+	}
+
+	if isLive {
+		playURL := PlayURLContext(ctx, source)
+		if playURL != "" && m.config.Verbose {
 			m.logger.WithFields(logrus.Fields{
-				"room_id":  roomInfo.RoomID,
-				"platform": roomInfo.Platform,
-				"status":   statusStr,
-			}).Info("Room status update")
-		}
-
-		if status {
-			playURL := source.GetPlayURL()
-			if playURL != "" && m.config.Verbose {
-				m.logger.WithFields(logrus.Fields{
-					"room_id": roomInfo.RoomID,
-					"play_url": playURL,
-				}).Debug("Room play URL retrieved")
-			}
+				"room_id": roomInfo.RoomID,
+				"play_url": playURL,
+			}).Debug("Room play URL retrieved")
+		}
+	}
+}
+
+// statusNotice is a notification sent for a status change
+type statusNotice int
+
+const (
+	noticeLiveStart statusNotice = iota
+	noticeLiveEnd
+	noticeRotationStart
+	noticeRotationEnd
+)
+
+// statusNoticeNames are the names of notices in the state file
+var statusNoticeNames = map[statusNotice]string{
+	noticeLiveStart:     "live_start",
+	noticeLiveEnd:       "live_end",
+	noticeRotationStart: "rotation_start",
+	noticeRotationEnd:   "rotation_end",
+}
+
+// String returns the notice's name
+func (n statusNotice) String() string {
+	return statusNoticeNames[n]
+}
+
+// parseStatusNotice returns the notice with the given name
+func parseStatusNotice(name string) (statusNotice, bool) {
+	for notice, noticeName := range statusNoticeNames {
+		if noticeName == name {
+			return notice, true
+		}
+	}
+	return 0, false
+}
+
+// statusNotices returns the notifications for a change from previous to
+// status; known is false for a room's first observation. A live stream
+// that ends into rotation playback is reported as ended. Rotation itself
+// is only reported for rooms with notifyRotation set.
+func statusNotices(previous models.LiveStatus, known bool, status models.LiveStatus, notifyRotation bool) []statusNotice {
+	var notices []statusNotice
+	switch {
+	case status == models.StatusLive:
+		notices = append(notices, noticeLiveStart)
+	case known && previous == models.StatusLive, !known && status == models.StatusOffline:
+		notices = append(notices, noticeLiveEnd)
+	}
+
+	if notifyRotation {
+		if status == models.StatusRotation {
+			notices = append(notices, noticeRotationStart)
+		} else if known && previous == models.StatusRotation && status == models.StatusOffline {
+			notices = append(notices, noticeRotationEnd)
 		}
 	}
+	return notices
 }
 
 // cleanup performs cleanup operations when stopping
 func (m *Monitor) cleanup() {
 	m.logger.Info("Cleaning up monitor resources...")
-	for key, source := range m.sources {
+	for key, source := range m.monitoredSources() {
 		if m.config.Verbose {
 			m.logger.Debugf("Closing message listener for %s", key)
 		}
 		source.CloseMsgListener()
 	}
+	m.persistState()
+	m.closeHistory()
 }
\ No newline at end of file
@@ -1,11 +1,21 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -78,7 +88,7 @@ func TestNewMonitor(t *testing.T) {
 		configData := Config{
 			Rooms: []RoomConfig{
 				{Platform: "bilibili", RoomID: "123", Enabled: true},
-				{Platform: "unknown", RoomID: "456", Enabled: true}, // This should be skipped
+				{Platform: "unknown", RoomID: "456", Enabled: false}, // Disabled rooms are not validated
 			},
 			Interval: "30s",
 			Verbose:  false,
@@ -98,7 +108,36 @@ func TestNewMonitor(t *testing.T) {
 		monitor, err := NewMonitor(tmpFile.Name())
 		assert.NoError(t, err)
 		assert.NotNil(t, monitor)
-		assert.Len(t, monitor.sources, 1) // Only bilibili source should be created
+		// Only the bilibili room is monitored, or retried every check round
+		// if it cannot be resolved to its real room ID
+		assert.Equal(t, 1, len(monitor.sources)+len(monitor.unresolved))
+	})
+
+	t.Run("with unknown platform", func(t *testing.T) {
+		configData := Config{
+			Rooms: []RoomConfig{
+				{Platform: "bilibili", RoomID: "123", Enabled: true},
+				{Platform: "unknown", RoomID: "456", Enabled: true},
+			},
+			Interval: "30s",
+		}
+
+		data, err := json.Marshal(configData)
+		require.NoError(t, err)
+
+		tmpFile, err := os.CreateTemp("", "test-config-*.json")
+		require.NoError(t, err)
+		defer os.Remove(tmpFile.Name())
+
+		_, err = tmpFile.Write(data)
+		require.NoError(t, err)
+		tmpFile.Close()
+
+		monitor, err := NewMonitor(tmpFile.Name())
+		assert.Error(t, err)
+		assert.Nil(t, monitor)
+		assert.Contains(t, err.Error(), `unsupported platform "unknown"`)
+		assert.Contains(t, err.Error(), "bilibili")
 	})
 
 	t.Run("with no enabled rooms", func(t *testing.T) {
@@ -128,9 +167,33 @@ func TestNewMonitor(t *testing.T) {
 	})
 }
 
+func TestNewMonitor_MergesResolvedRooms(t *testing.T) {
+	registerResolvingPlatform(t, "merge-test")
+
+	configData := Config{
+		Rooms: []RoomConfig{
+			{Platform: "merge-test", RoomID: "short-1", Enabled: true, NotifyRotation: true},
+			{Platform: "merge-test", RoomID: "1", Enabled: true},
+			{Platform: "merge-test", RoomID: "url-2", Enabled: true},
+			{Platform: "merge-test", RoomID: "missing", Enabled: true},
+		},
+	}
+	data, err := json.Marshal(configData)
+	require.NoError(t, err)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configFile, data, 0644))
+
+	m, err := NewMonitor(configFile)
+	require.NoError(t, err)
+
+	// A short and a real reference to the same room give one source
+	assert.Len(t, m.sources, 2)
+	assert.Contains(t, m.sources, "merge-test:1")
+	assert.Contains(t, m.sources, "merge-test:2")
+	assert.True(t, m.rooms["merge-test:1"].NotifyRotation, "the first entry's settings are kept")
+}
+
 func TestMonitor_RunAndStop(t *testing.T) {
-	t.Skip("Skipping flaky test - monitor Stop() times out due to blocking network calls that don't respect context cancellation. Re-enable after implementing proper context handling in GetStatus()")
-	
 	// Create a monitor with minimal config
 	configData := Config{
 		Rooms: []RoomConfig{
@@ -199,4 +262,675 @@ func TestMonitor_RunWithNoSources(t *testing.T) {
 	err = monitor.Run()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no valid stream sources")
-}
\ No newline at end of file
+}
+// fakeSource is an in-memory StreamSource used to drive the monitor in tests
+type fakeSource struct {
+	mu        sync.Mutex
+	status    models.LiveStatus
+	err       error
+	checks    int
+	connected bool
+	events    chan service.DanmakuEvent
+	roomInfo  models.RoomInfo
+	gate      chan struct{} // When set, GetStatus blocks until it is closed
+}
+
+func newFakeSource(roomID string) *fakeSource {
+	return &fakeSource{
+		events:   make(chan service.DanmakuEvent, 10),
+		roomInfo: models.RoomInfo{Platform: "fake", RoomID: roomID},
+	}
+}
+
+func (f *fakeSource) GetStatus() (models.LiveStatus, error) {
+	f.mu.Lock()
+	f.checks++
+	gate := f.gate
+	f.mu.Unlock()
+
+	if gate != nil {
+		<-gate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status, f.err
+}
+
+func (f *fakeSource) setStatus(status models.LiveStatus, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+	f.err = err
+}
+
+func (f *fakeSource) GetRoomInfo() models.RoomInfo { return f.roomInfo }
+func (f *fakeSource) GetPlayURL() string           { return "" }
+func (f *fakeSource) StartMsgListener()            {}
+func (f *fakeSource) CloseMsgListener()            {}
+
+func (f *fakeSource) Events() <-chan service.DanmakuEvent { return f.events }
+
+func (f *fakeSource) MsgConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *fakeSource) setConnected(connected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+}
+
+func (f *fakeSource) checkCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.checks
+}
+
+// newTestMonitor builds a monitor around the given sources without loading a config file
+func newTestMonitor(t *testing.T, config Config, sources map[string]StreamSource) *Monitor {
+	t.Helper()
+
+	if config.Interval == "" {
+		config.Interval = "30s"
+	}
+	if config.ReconcileInterval == "" {
+		config.ReconcileInterval = "5m"
+	}
+
+	m, err := NewMonitor("")
+	require.NoError(t, err)
+	m.config = config
+	m.sources = sources
+	for _, room := range config.Rooms {
+		m.rooms[fmt.Sprintf("%s:%s", room.Platform, room.RoomID)] = room
+	}
+	return m
+}
+
+func TestMonitor_PushedStatusMessages(t *testing.T) {
+	source := newFakeSource("1")
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{"fake:1": source})
+
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}})
+	assert.Equal(t, models.StatusLive, m.lastStatus["fake:1"])
+
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventDanmaku}})
+	assert.Equal(t, models.StatusLive, m.lastStatus["fake:1"], "chat messages must not change status")
+
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}})
+	assert.Equal(t, models.StatusOffline, m.lastStatus["fake:1"])
+
+	// Events for unknown sources are ignored
+	assert.NotPanics(t, func() {
+		m.handleSourceEvent(sourceEvent{key: "fake:2", event: service.DanmakuEvent{Type: service.DanmakuEventLive}})
+	})
+	assert.NotContains(t, m.lastStatus, "fake:2")
+}
+
+func TestMonitor_PollingFallsBackWithoutMessageConnection(t *testing.T) {
+	source := newFakeSource("1")
+	m := newTestMonitor(t, Config{ReconcileInterval: "1h"}, map[string]StreamSource{"fake:1": source})
+
+	// Without a message connection every tick polls
+	m.checkAllSources()
+	m.checkAllSources()
+	assert.Equal(t, 2, source.checkCount())
+
+	// With a connection the room is only reconciled once per interval
+	source.setConnected(true)
+	m.checkAllSources()
+	m.checkAllSources()
+	assert.Equal(t, 2, source.checkCount())
+
+	// Losing the connection returns the room to fast polling
+	source.setConnected(false)
+	m.checkAllSources()
+	assert.Equal(t, 3, source.checkCount())
+}
+
+func TestMonitor_RunAppliesPushedMessages(t *testing.T) {
+	source := newFakeSource("1")
+	source.setConnected(true)
+	m := newTestMonitor(t, Config{Interval: "1h"}, map[string]StreamSource{"fake:1": source})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+
+	source.events <- service.DanmakuEvent{Type: service.DanmakuEventLive}
+
+	// The live message is applied long before the next poll
+	assert.Eventually(t, func() bool {
+		status, ok := m.Status("fake:1")
+		return ok && status == models.StatusLive
+	}, 2*time.Second, 10*time.Millisecond)
+
+	m.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Monitor did not stop within timeout")
+	}
+}
+
+func TestMonitor_UnknownStatusKeepsLastState(t *testing.T) {
+	source := newFakeSource("1")
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+
+	// API errors must not look like the stream ended
+	source.setStatus(models.StatusUnknown, errors.New("network error"))
+	m.checkAllSources()
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+	assert.Equal(t, 2, m.ConsecutiveFailures("fake:1"))
+
+	// An unknown status without an error is treated the same way
+	source.setStatus(models.StatusUnknown, nil)
+	m.checkAllSources()
+	assert.Equal(t, 3, m.ConsecutiveFailures("fake:1"))
+
+	// A successful check resets the failure count and applies the new state
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusOffline, status)
+	assert.Equal(t, 0, m.ConsecutiveFailures("fake:1"))
+}
+
+func TestMonitor_UnknownStatusBeforeFirstCheck(t *testing.T) {
+	source := newFakeSource("1")
+	source.setStatus(models.StatusUnknown, errors.New("network error"))
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{"fake:1": source})
+
+	m.checkAllSources()
+	_, checked := m.Status("fake:1")
+	assert.False(t, checked, "an unknown status must not be recorded")
+	assert.Equal(t, 1, m.ConsecutiveFailures("fake:1"))
+}
+
+func TestMonitor_OfflineConfirmations(t *testing.T) {
+	source := newFakeSource("1")
+	config := Config{Rooms: []RoomConfig{{Platform: "fake", RoomID: "1", Enabled: true, OfflineConfirmations: 3}}}
+	m := newTestMonitor(t, config, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	start := m.sessionStart["fake:1"]
+	require.False(t, start.IsZero())
+
+	// The first offline observations only mark the room as pending
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	m.checkAllSources()
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+	require.Contains(t, m.pendingOffline, "fake:1")
+	assert.Equal(t, 2, m.pendingOffline["fake:1"].observations)
+
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusOffline, status)
+	assert.NotContains(t, m.pendingOffline, "fake:1")
+	assert.NotContains(t, m.sessionStart, "fake:1")
+}
+
+func TestMonitor_FlapResumesSession(t *testing.T) {
+	source := newFakeSource("1")
+	config := Config{Rooms: []RoomConfig{{Platform: "fake", RoomID: "1", Enabled: true, OfflineConfirmations: 2}}}
+	m := newTestMonitor(t, config, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	start := m.sessionStart["fake:1"]
+
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	require.Contains(t, m.pendingOffline, "fake:1")
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+	assert.NotContains(t, m.pendingOffline, "fake:1")
+	assert.Equal(t, start, m.sessionStart["fake:1"], "a flap must resume the same session")
+
+	// The confirmation count starts over after a flap
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+}
+
+func TestMonitor_OfflineGracePeriod(t *testing.T) {
+	source := newFakeSource("1")
+	config := Config{Rooms: []RoomConfig{{Platform: "fake", RoomID: "1", Enabled: true, OfflineGracePeriod: "1h"}}}
+	m := newTestMonitor(t, config, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+
+	source.setStatus(models.StatusOffline, nil)
+	for i := 0; i < 5; i++ {
+		m.checkAllSources()
+	}
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+
+	// Once the grace period has passed the next observation confirms it
+	m.pendingOffline["fake:1"].since = time.Now().Add(-2 * time.Hour)
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusOffline, status)
+}
+
+func TestMonitor_PushedPreparingIsDebounced(t *testing.T) {
+	source := newFakeSource("1")
+	source.setConnected(true)
+	config := Config{Rooms: []RoomConfig{{Platform: "fake", RoomID: "1", Enabled: true, OfflineConfirmations: 2}}}
+	m := newTestMonitor(t, config, map[string]StreamSource{"fake:1": source})
+
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}})
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}})
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+
+	// A pending room is polled even with a working message connection
+	m.lastChecked["fake:1"] = time.Now()
+	assert.True(t, m.pollDue("fake:1", source, time.Now()))
+}
+
+func TestMonitor_DefaultOfflineIsImmediate(t *testing.T) {
+	source := newFakeSource("1")
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusOffline, status)
+}
+
+func TestStatusNotices(t *testing.T) {
+	tests := []struct {
+		name           string
+		previous       models.LiveStatus
+		known          bool
+		status         models.LiveStatus
+		notifyRotation bool
+		want           []statusNotice
+	}{
+		{"first seen live", models.StatusUnknown, false, models.StatusLive, false, []statusNotice{noticeLiveStart}},
+		{"first seen offline", models.StatusUnknown, false, models.StatusOffline, false, []statusNotice{noticeLiveEnd}},
+		{"first seen rotating", models.StatusUnknown, false, models.StatusRotation, false, nil},
+		{"live ends", models.StatusLive, true, models.StatusOffline, true, []statusNotice{noticeLiveEnd}},
+		{"live ends into rotation", models.StatusLive, true, models.StatusRotation, false, []statusNotice{noticeLiveEnd}},
+		{"live ends into notified rotation", models.StatusLive, true, models.StatusRotation, true, []statusNotice{noticeLiveEnd, noticeRotationStart}},
+		{"rotation starts", models.StatusOffline, true, models.StatusRotation, true, []statusNotice{noticeRotationStart}},
+		{"rotation starts silently", models.StatusOffline, true, models.StatusRotation, false, nil},
+		{"rotation ends", models.StatusRotation, true, models.StatusOffline, true, []statusNotice{noticeRotationEnd}},
+		{"rotation ends silently", models.StatusRotation, true, models.StatusOffline, false, nil},
+		{"live after rotation", models.StatusRotation, true, models.StatusLive, true, []statusNotice{noticeLiveStart}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statusNotices(tt.previous, tt.known, tt.status, tt.notifyRotation))
+		})
+	}
+}
+
+func TestMonitor_RotationStatus(t *testing.T) {
+	source := newFakeSource("1")
+	config := Config{Rooms: []RoomConfig{{Platform: "fake", RoomID: "1", Enabled: true, OfflineConfirmations: 2}}}
+	m := newTestMonitor(t, config, map[string]StreamSource{"fake:1": source})
+
+	source.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+
+	// Rotation ends a live session like going offline, including the debounce
+	source.setStatus(models.StatusRotation, nil)
+	m.checkAllSources()
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusRotation, status)
+	assert.NotContains(t, m.sessionStart, "fake:1")
+
+	// Rotation and offline are distinct states
+	source.setStatus(models.StatusOffline, nil)
+	m.checkAllSources()
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusOffline, status)
+
+	// A pushed PREPARING message into rotation is applied directly
+	m.handleSourceEvent(sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing, Rotation: true}})
+	status, _ = m.Status("fake:1")
+	assert.Equal(t, models.StatusRotation, status)
+}
+
+// fakeBatcher records the rooms it is asked to refresh
+type fakeBatcher struct {
+	mu    sync.Mutex
+	calls [][]string
+	err   error
+}
+
+func (f *fakeBatcher) Refresh(ctx context.Context, roomIDs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]string(nil), roomIDs...))
+	return f.err
+}
+
+// batchedFakeSource is a fakeSource whose status is prefetched by a batcher
+type batchedFakeSource struct {
+	*fakeSource
+	batcher *fakeBatcher
+}
+
+func (b *batchedFakeSource) Batcher() (StatusBatcher, string) {
+	return b.batcher, b.roomInfo.RoomID
+}
+
+func TestMonitor_BatchPrefetch(t *testing.T) {
+	batcher := &fakeBatcher{}
+	first := &batchedFakeSource{fakeSource: newFakeSource("1"), batcher: batcher}
+	second := &batchedFakeSource{fakeSource: newFakeSource("2"), batcher: batcher}
+	plain := newFakeSource("3")
+
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{
+		"fake:1": first,
+		"fake:2": second,
+		"fake:3": plain,
+	})
+
+	m.checkAllSources()
+
+	// All batched rooms are refreshed in a single call before the checks
+	require.Len(t, batcher.calls, 1)
+	assert.ElementsMatch(t, []string{"1", "2"}, batcher.calls[0])
+	assert.Equal(t, 1, first.checkCount())
+	assert.Equal(t, 1, second.checkCount())
+	assert.Equal(t, 1, plain.checkCount())
+
+	// A failed batch still checks every room so each can fall back on its own
+	batcher.err = errors.New("batch failed")
+	first.setStatus(models.StatusLive, nil)
+	m.checkAllSources()
+	assert.Len(t, batcher.calls, 2)
+	assert.Equal(t, 2, first.checkCount())
+	assert.Equal(t, models.StatusLive, m.lastStatus["fake:1"])
+}
+
+func TestMonitor_BatchPrefetchSkipsRoomsNotDue(t *testing.T) {
+	batcher := &fakeBatcher{}
+	pushed := &batchedFakeSource{fakeSource: newFakeSource("1"), batcher: batcher}
+	polled := &batchedFakeSource{fakeSource: newFakeSource("2"), batcher: batcher}
+	pushed.setConnected(true)
+
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{
+		"fake:1": pushed,
+		"fake:2": polled,
+	})
+	m.lastChecked["fake:1"] = time.Now()
+
+	m.checkAllSources()
+
+	require.Len(t, batcher.calls, 1)
+	assert.Equal(t, []string{"2"}, batcher.calls[0])
+	assert.Equal(t, 0, pushed.checkCount())
+}
+
+// concurrencyProbe records how many status checks run at the same time
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+// probedSource is a fakeSource whose checks take a while and are counted by a probe
+type probedSource struct {
+	*fakeSource
+	probe *concurrencyProbe
+}
+
+func (p *probedSource) GetStatus() (models.LiveStatus, error) {
+	p.probe.mu.Lock()
+	p.probe.current++
+	if p.probe.current > p.probe.max {
+		p.probe.max = p.probe.current
+	}
+	p.probe.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	p.probe.mu.Lock()
+	p.probe.current--
+	p.probe.mu.Unlock()
+	return p.fakeSource.GetStatus()
+}
+
+func TestMonitor_ConcurrentChecks(t *testing.T) {
+	probe := &concurrencyProbe{}
+	sources := make(map[string]StreamSource)
+	for i := 1; i <= 6; i++ {
+		source := &probedSource{fakeSource: newFakeSource(fmt.Sprint(i)), probe: probe}
+		source.setStatus(models.StatusLive, nil)
+		sources[fmt.Sprintf("fake:%d", i)] = source
+	}
+	m := newTestMonitor(t, Config{MaxConcurrentChecks: 3}, sources)
+
+	m.checkAllSources()
+
+	// Checks run in parallel but never beyond the pool size
+	assert.Equal(t, 3, probe.max)
+	for key := range sources {
+		status, ok := m.Status(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, models.StatusLive, status, key)
+	}
+}
+
+func TestMonitor_CheckTimeout(t *testing.T) {
+	hung := newFakeSource("1")
+	hung.gate = make(chan struct{})
+	fast := newFakeSource("2")
+	fast.setStatus(models.StatusLive, nil)
+
+	m := newTestMonitor(t, Config{CheckTimeout: "50ms"}, map[string]StreamSource{
+		"fake:1": hung,
+		"fake:2": fast,
+	})
+
+	start := time.Now()
+	m.checkAllSources()
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The hung room counts as a failed check and does not hold up the others
+	_, checked := m.Status("fake:1")
+	assert.False(t, checked)
+	assert.Equal(t, 1, m.ConsecutiveFailures("fake:1"))
+	status, _ := m.Status("fake:2")
+	assert.Equal(t, models.StatusLive, status)
+
+	// A room whose previous check has not returned is skipped
+	m.checkAllSources()
+	assert.Equal(t, 1, hung.checkCount())
+	assert.Equal(t, 2, fast.checkCount())
+
+	// Once the abandoned call returns the room is checked again
+	hung.setStatus(models.StatusOffline, nil)
+	close(hung.gate)
+	assert.Eventually(t, func() bool {
+		m.checkAllSources()
+		_, checked := m.Status("fake:1")
+		return checked
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, m.ConsecutiveFailures("fake:1"))
+}
+
+// slowInfoSource answers status checks at once but blocks room info and
+// play URL requests until their context is done
+type slowInfoSource struct {
+	*fakeSource
+}
+
+func (s slowInfoSource) GetStatusContext(ctx context.Context) (models.LiveStatus, error) {
+	return s.GetStatus()
+}
+
+func (s slowInfoSource) GetRoomInfoContext(ctx context.Context) models.RoomInfo {
+	<-ctx.Done()
+	return s.GetRoomInfo()
+}
+
+func (s slowInfoSource) GetPlayURLContext(ctx context.Context) string {
+	<-ctx.Done()
+	return s.GetPlayURL()
+}
+
+func TestMonitor_CheckTimeoutCoversRoomInfo(t *testing.T) {
+	source := slowInfoSource{newFakeSource("1")}
+	source.setStatus(models.StatusLive, nil)
+	m := newTestMonitor(t, Config{CheckTimeout: "50ms"}, map[string]StreamSource{"fake:1": source})
+
+	start := time.Now()
+	m.checkSource("fake:1", source)
+	assert.Less(t, time.Since(start), time.Second)
+	status, _ := m.Status("fake:1")
+	assert.Equal(t, models.StatusLive, status)
+}
+
+func TestMonitor_TickOverruns(t *testing.T) {
+	hung := newFakeSource("1")
+	hung.gate = make(chan struct{})
+	m := newTestMonitor(t, Config{CheckTimeout: "1h"}, map[string]StreamSource{"fake:1": hung})
+
+	m.startCheckRound(time.Millisecond)
+	assert.Eventually(t, func() bool { return hung.checkCount() == 1 }, time.Second, time.Millisecond)
+
+	// Ticks during a running round are skipped and counted
+	m.startCheckRound(time.Millisecond)
+	m.startCheckRound(time.Millisecond)
+	assert.Equal(t, int64(2), m.TickOverruns())
+	assert.Equal(t, 1, hung.checkCount())
+
+	hung.setStatus(models.StatusOffline, nil)
+	close(hung.gate)
+	m.rounds.Wait()
+	status, checked := m.Status("fake:1")
+	assert.True(t, checked)
+	assert.Equal(t, models.StatusOffline, status)
+
+	m.startCheckRound(time.Millisecond)
+	m.rounds.Wait()
+	assert.Equal(t, 2, hung.checkCount())
+	assert.Equal(t, int64(2), m.TickOverruns())
+}
+
+func TestMonitor_StopAbortsInFlightChecks(t *testing.T) {
+	// The server never answers, so only cancellation can end the checks
+	requests := make(chan struct{}, 10)
+	var active atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active.Add(1)
+		defer active.Add(-1)
+		select {
+		case requests <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL)
+
+	source, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+	source.service, err = service.NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	m := newTestMonitor(t, Config{Interval: "10ms", CheckTimeout: "1h"}, map[string]StreamSource{"bilibili:76": source})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+
+	select {
+	case <-requests:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Status check did not start")
+	}
+
+	m.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Monitor did not stop within timeout")
+	}
+
+	// In-flight requests are cancelled rather than left to time out
+	assert.Eventually(t, func() bool { return active.Load() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestMonitor_RiskControlReportsUnknown(t *testing.T) {
+	var blocked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blocked.Load() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"room_id":22637261,"short_id":76,"live_status":0}}`))
+	}))
+	defer server.Close()
+
+	client := service.NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.Wbi.NavURL = server.URL + "/nav"
+	client.CacheTTL = 0
+	client.CoolDownBase = 50 * time.Millisecond
+
+	source, err := NewBilibiliStreamSource("76")
+	require.NoError(t, err)
+	source.service, err = service.NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	m := newTestMonitor(t, Config{}, map[string]StreamSource{"bilibili:76": source})
+
+	m.checkAllSources()
+	status, _ := m.Status("bilibili:76")
+	assert.Equal(t, models.StatusOffline, status)
+
+	// A blocked room is reported as unknown rather than offline
+	blocked.Store(true)
+	m.checkAllSources()
+	status, checked := m.Status("bilibili:76")
+	assert.True(t, checked)
+	assert.Equal(t, models.StatusUnknown, status)
+	assert.Equal(t, 1, m.ConsecutiveFailures("bilibili:76"))
+
+	// Once the cool-down ends and the API answers, the real status returns
+	blocked.Store(false)
+	time.Sleep(client.CoolDownRemaining())
+	m.checkAllSources()
+	status, _ = m.Status("bilibili:76")
+	assert.Equal(t, models.StatusOffline, status)
+	assert.Equal(t, 0, m.ConsecutiveFailures("bilibili:76"))
+}
@@ -0,0 +1,264 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nick3/restreamer_monitor_go/service"
+)
+
+// SourceFactory creates a stream source for a room.
+// The full configuration is passed for platform-wide settings.
+type SourceFactory func(room RoomConfig, config Config) (StreamSource, error)
+
+// Capabilities describes the optional features a platform supports
+type Capabilities struct {
+	Messages         bool // Pushed room messages via StartMsgListener
+	PlayURL          bool // GetPlayURL returns a URL usable as relay input
+	QualitySelection bool // The source picks its stream by RelayConfig.Quality and Codec
+}
+
+// Platform describes a live platform that can provide stream sources
+type Platform struct {
+	Name     string
+	Factory  SourceFactory
+	Validate func(room RoomConfig) error // Optional platform-specific config checks
+	// ValidateStream optionally checks a relay's quality and codec on
+	// platforms with quality selection
+	ValidateStream func(quality, codec string) error
+	// Resolve optionally turns room references such as URLs into canonical
+	// room IDs. It gets all enabled references of the platform at once and
+	// returns the IDs it resolved, with an error for the others.
+	Resolve      func(ctx context.Context, refs []string, config Config) (map[string]string, error)
+	Capabilities Capabilities
+}
+
+var (
+	platformsMu sync.RWMutex
+	platforms   = make(map[string]Platform)
+)
+
+// RegisterPlatform makes a platform available by name.
+// It panics if the name is empty, the factory is nil or the name is already registered.
+func RegisterPlatform(p Platform) {
+	platformsMu.Lock()
+	defer platformsMu.Unlock()
+
+	if p.Name == "" {
+		panic("monitor: RegisterPlatform with empty name")
+	}
+	if p.Factory == nil {
+		panic("monitor: RegisterPlatform factory is nil for " + p.Name)
+	}
+	if _, exists := platforms[p.Name]; exists {
+		panic("monitor: RegisterPlatform called twice for " + p.Name)
+	}
+	platforms[p.Name] = p
+}
+
+// LookupPlatform returns the registered platform with the given name
+func LookupPlatform(name string) (Platform, bool) {
+	platformsMu.RLock()
+	defer platformsMu.RUnlock()
+
+	p, ok := platforms[name]
+	return p, ok
+}
+
+// RegisteredPlatforms returns the sorted names of all registered platforms
+func RegisteredPlatforms() []string {
+	platformsMu.RLock()
+	defer platformsMu.RUnlock()
+
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupPlatform is LookupPlatform with an error listing the registered platforms
+func lookupPlatform(name string) (Platform, error) {
+	p, ok := LookupPlatform(name)
+	if !ok {
+		return Platform{}, fmt.Errorf("unsupported platform %q (registered platforms: %s)", name, strings.Join(RegisteredPlatforms(), ", "))
+	}
+	return p, nil
+}
+
+// ValidateRoom checks that a room refers to a registered platform
+// and passes that platform's validator
+func ValidateRoom(room RoomConfig) error {
+	p, err := lookupPlatform(room.Platform)
+	if err != nil {
+		return err
+	}
+	if p.Validate != nil {
+		if err := p.Validate(room); err != nil {
+			return fmt.Errorf("invalid %s room %q: %w", p.Name, room.RoomID, err)
+		}
+	}
+	return nil
+}
+
+// ValidateRelay checks that a relay source can be used as relay input
+func ValidateRelay(relay RelayConfig) error {
+	room := relay.Source.RoomConfig()
+	if err := ValidateRoom(room); err != nil {
+		return err
+	}
+
+	p, _ := LookupPlatform(room.Platform)
+	if !p.Capabilities.PlayURL {
+		return fmt.Errorf("platform %q does not provide play URLs and cannot be used as a relay source", p.Name)
+	}
+	if p.Capabilities.QualitySelection && p.ValidateStream != nil {
+		if err := p.ValidateStream(relay.Quality, relay.Codec); err != nil {
+			return err
+		}
+	}
+	if relay.Proxy != "" {
+		u, err := service.ParseProxy(relay.Proxy)
+		if err != nil {
+			return err
+		}
+		// FFmpeg's -http_proxy only speaks plain HTTP to the proxy
+		if u.Scheme != "http" {
+			return fmt.Errorf("relay proxy must be an http proxy, FFmpeg cannot pull streams through %s proxies", u.Scheme)
+		}
+	}
+	return nil
+}
+
+// Validate checks all enabled rooms and relays against the platform registry
+func (c Config) Validate() error {
+	var errs []error
+
+	for _, room := range c.Rooms {
+		if !room.Enabled {
+			continue
+		}
+		if err := ValidateRoom(room); err != nil {
+			errs = append(errs, fmt.Errorf("room %s: %w", room.RoomID, err))
+		}
+	}
+
+	for _, relay := range c.Relays {
+		if !relay.Enabled {
+			continue
+		}
+		if err := ValidateRelay(relay); err != nil {
+			errs = append(errs, fmt.Errorf("relay %s: %w", relay.Name, err))
+			continue
+		}
+		// Relays pull through the platform proxy unless they set their own
+		if relay.Proxy == "" {
+			platform := relay.Source.Platform
+			if u, _ := service.ParseProxy(c.Platforms.Proxy(platform)); u != nil && u.Scheme != "http" {
+				errs = append(errs, fmt.Errorf("relay %s: FFmpeg cannot pull streams through the %s proxy of platforms.%s, set an http proxy on the relay", relay.Name, u.Scheme, platform))
+			}
+		}
+	}
+
+	if c.usesPlatform("twitch") && (c.Platforms.Twitch.ClientID == "" || c.Platforms.Twitch.ClientSecret == "") {
+		errs = append(errs, fmt.Errorf("platforms.twitch: client_id and client_secret are required for twitch rooms"))
+	}
+	if err := c.Platforms.Bilibili.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("platforms.bilibili: %w", err))
+	}
+	for _, platform := range []string{"douyu", "huya", "twitch", "url"} {
+		if _, err := service.ParseProxy(c.Platforms.Proxy(platform)); err != nil {
+			errs = append(errs, fmt.Errorf("platforms.%s: %w", platform, err))
+		}
+	}
+	if c.CatchUpSummary && c.StateFile == "" {
+		errs = append(errs, fmt.Errorf("catch_up_summary requires state_file"))
+	}
+	if c.FollowSync != nil {
+		if err := c.FollowSync.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("follow_sync: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// usesPlatform reports whether an enabled room or relay is on the platform
+func (c Config) usesPlatform(name string) bool {
+	for _, room := range c.Rooms {
+		if room.Enabled && room.Platform == name {
+			return true
+		}
+	}
+	for _, relay := range c.Relays {
+		if relay.Enabled && relay.Source.Platform == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveRooms replaces the room references of rooms on platforms with a
+// resolver by their canonical room IDs, keeping their order. Rooms that
+// cannot be resolved are returned as unresolved and reported in the error.
+func ResolveRooms(ctx context.Context, rooms []RoomConfig, config Config) (resolvedRooms, unresolved []RoomConfig, err error) {
+	refs := make(map[string][]string)
+	for _, room := range rooms {
+		refs[room.Platform] = append(refs[room.Platform], room.RoomID)
+	}
+
+	resolved := make(map[string]map[string]string)
+	var errs []error
+	for name, platformRefs := range refs {
+		p, ok := LookupPlatform(name)
+		if !ok || p.Resolve == nil {
+			continue
+		}
+		ids, err := p.Resolve(ctx, platformRefs, config)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		resolved[name] = ids
+	}
+
+	resolvedRooms = make([]RoomConfig, 0, len(rooms))
+	for _, room := range rooms {
+		if ids, ok := resolved[room.Platform]; ok {
+			id, ok := ids[room.RoomID]
+			if !ok {
+				unresolved = append(unresolved, room)
+				continue
+			}
+			room.RoomID = id
+		}
+		resolvedRooms = append(resolvedRooms, room)
+	}
+	return resolvedRooms, unresolved, errors.Join(errs...)
+}
+
+// ResolveRoom is ResolveRooms for a single room
+func ResolveRoom(ctx context.Context, room RoomConfig, config Config) (RoomConfig, error) {
+	rooms, _, err := ResolveRooms(ctx, []RoomConfig{room}, config)
+	if len(rooms) == 0 {
+		if err == nil {
+			err = fmt.Errorf("room %s could not be resolved", room.RoomID)
+		}
+		return room, err
+	}
+	return rooms[0], nil
+}
+
+// NewStreamSource validates a room and creates its source through the registry
+func NewStreamSource(room RoomConfig, config Config) (StreamSource, error) {
+	if err := ValidateRoom(room); err != nil {
+		return nil, err
+	}
+
+	p, _ := LookupPlatform(room.Platform)
+	return p.Factory(room, config)
+}
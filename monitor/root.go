@@ -1,14 +1,87 @@
 package monitor
 
 import (
+	"context"
+
 	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
 )
 
 // StreamSource defines the interface for live stream sources
 type StreamSource interface {
-	GetStatus() bool
+	// GetStatus returns StatusUnknown together with the error when the
+	// status cannot be determined; callers must not treat that as offline
+	GetStatus() (models.LiveStatus, error)
 	GetRoomInfo() models.RoomInfo
 	GetPlayURL() string
 	StartMsgListener()
 	CloseMsgListener()
-}
\ No newline at end of file
+}
+
+// MessageSource is implemented by sources that receive pushed room messages.
+// The monitor uses it to react to live start/end without waiting for a poll.
+type MessageSource interface {
+	Events() <-chan service.DanmakuEvent
+	MsgConnected() bool
+}
+
+// EventSource is implemented by message sources that track the live status
+// themselves. The monitor passes them pushed messages before applying them,
+// so that the room info it reads belongs to the pushed session.
+type EventSource interface {
+	ApplyEvent(event service.DanmakuEvent)
+}
+
+// StatusBatcher fetches the status of many rooms at once so that their
+// sources can answer GetStatus without a request of their own
+type StatusBatcher interface {
+	Refresh(ctx context.Context, roomIDs []string) error
+}
+
+// BatchedSource is implemented by sources whose status can be prefetched.
+// Batcher returns nil when the source polls on its own.
+type BatchedSource interface {
+	Batcher() (batcher StatusBatcher, roomID string)
+}
+
+// ContextSource is implemented by sources whose requests can be cancelled.
+// The monitor and relay use it so that stopping aborts in-flight requests.
+type ContextSource interface {
+	GetStatusContext(ctx context.Context) (models.LiveStatus, error)
+	GetRoomInfoContext(ctx context.Context) models.RoomInfo
+	GetPlayURLContext(ctx context.Context) string
+}
+
+// CandidateSource is implemented by sources that offer several variants of
+// their stream. Relays use it to pick the stream by quality and codec.
+type CandidateSource interface {
+	// StreamCandidatesContext returns the variants ranked from most to least preferred
+	StreamCandidatesContext(ctx context.Context, quality, codec string) ([]models.StreamCandidate, error)
+}
+
+// StatusContext returns the source's status, cancelling the check when ctx
+// is done if the source supports it
+func StatusContext(ctx context.Context, source StreamSource) (models.LiveStatus, error) {
+	if cs, ok := source.(ContextSource); ok {
+		return cs.GetStatusContext(ctx)
+	}
+	return source.GetStatus()
+}
+
+// RoomInfoContext returns the source's room information, cancelling
+// requests when ctx is done if the source supports it
+func RoomInfoContext(ctx context.Context, source StreamSource) models.RoomInfo {
+	if cs, ok := source.(ContextSource); ok {
+		return cs.GetRoomInfoContext(ctx)
+	}
+	return source.GetRoomInfo()
+}
+
+// PlayURLContext returns the source's play URL, cancelling requests when
+// ctx is done if the source supports it
+func PlayURLContext(ctx context.Context, source StreamSource) string {
+	if cs, ok := source.(ContextSource); ok {
+		return cs.GetPlayURLContext(ctx)
+	}
+	return source.GetPlayURL()
+}
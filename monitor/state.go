@@ -0,0 +1,186 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+)
+
+// roomState is the persisted state of a room
+type roomState struct {
+	Status       models.LiveStatus `json:"status"`
+	SessionStart time.Time         `json:"session_start"`
+	LastNotice   string            `json:"last_notice,omitempty"`
+	LastNoticeAt time.Time         `json:"last_notice_at"`
+}
+
+// monitorState is the content of the state file
+type monitorState struct {
+	SavedAt time.Time            `json:"saved_at"`
+	Rooms   map[string]roomState `json:"rooms"`
+}
+
+// sentNotice is the last notification sent for a room
+type sentNotice struct {
+	notice   statusNotice
+	at       time.Time
+	restored bool // Sent by the last run
+}
+
+// loadState reads the state file. A missing file gives an empty state.
+func loadState(path string) (monitorState, error) {
+	state := monitorState{Rooms: make(map[string]roomState)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Rooms == nil {
+		state.Rooms = make(map[string]roomState)
+	}
+	return state, nil
+}
+
+// saveState writes the state file through a temporary file, so a crash
+// never leaves a truncated file behind
+func saveState(path string, state monitorState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// restoreState loads the rooms' last status, session start and last
+// notification from the state file, so that the first check after a
+// restart only notifies about real changes
+func (m *Monitor) restoreState() {
+	state, err := loadState(m.config.StateFile)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to load monitor state, starting fresh")
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, room := range state.Rooms {
+		if room.Status == models.StatusUnknown {
+			continue
+		}
+		m.lastStatus[key] = room.Status
+		if !room.SessionStart.IsZero() {
+			m.sessionStart[key] = room.SessionStart
+		}
+		if notice, ok := parseStatusNotice(room.LastNotice); ok {
+			m.lastNotices[key] = sentNotice{notice: notice, at: room.LastNoticeAt, restored: true}
+		}
+		if m.config.CatchUpSummary {
+			m.restored[key] = true
+		}
+	}
+
+	if len(state.Rooms) > 0 {
+		m.logger.WithField("saved_at", state.SavedAt).Infof("Restored state of %d rooms", len(state.Rooms))
+	}
+}
+
+// persistState writes the current state of the monitored rooms to the
+// state file. Restored rooms that are no longer configured are dropped.
+func (m *Monitor) persistState() {
+	if m.config.StateFile == "" {
+		return
+	}
+
+	m.mu.RLock()
+	state := monitorState{SavedAt: time.Now(), Rooms: make(map[string]roomState, len(m.lastStatus))}
+	for key, status := range m.lastStatus {
+		if _, monitored := m.sources[key]; !monitored {
+			continue
+		}
+		room := roomState{Status: status, SessionStart: m.sessionStart[key]}
+		if sent, ok := m.lastNotices[key]; ok {
+			room.LastNotice = sent.notice.String()
+			room.LastNoticeAt = sent.at
+		}
+		state.Rooms[key] = room
+	}
+	m.mu.RUnlock()
+
+	// Writes are serialized so that an older snapshot never replaces a newer one
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	if state.SavedAt.Before(m.stateSavedAt) {
+		return
+	}
+	if err := saveState(m.config.StateFile, state); err != nil {
+		m.logger.WithError(err).Warn("Failed to save monitor state")
+		return
+	}
+	m.stateSavedAt = state.SavedAt
+}
+
+// recordNotice remembers the last notification sent for a room
+func (m *Monitor) recordNotice(key string, notice statusNotice) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastNotices[key] = sentNotice{notice: notice, at: time.Now()}
+}
+
+// queueCatchUp adds a change seen on a restored room's first check to the
+// catch-up summary
+func (m *Monitor) queueCatchUp(change models.StatusChange) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.catchUp = append(m.catchUp, change)
+}
+
+// flushCatchUp sends the catch-up summary once the first check round after
+// a restart is done. Restored rooms that were not observed yet notify
+// normally from then on.
+func (m *Monitor) flushCatchUp() {
+	m.mu.Lock()
+	changes := m.catchUp
+	m.catchUp = nil
+	m.restored = make(map[string]bool)
+	m.mu.Unlock()
+
+	if len(changes) == 0 {
+		return
+	}
+
+	m.logger.WithField("changes", len(changes)).Info("Sending catch-up summary of changes since the last run")
+	if m.notificationMgr != nil {
+		m.notificationMgr.SendCatchUpSummary(changes)
+	}
+}
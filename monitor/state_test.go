@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStateMonitor starts a monitor from config, as after a restart,
+// watching a fake source as fake:1
+func newStateMonitor(t *testing.T, config Config) *Monitor {
+	t.Helper()
+
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configFile, data, 0644))
+
+	m, err := NewMonitor(configFile)
+	require.NoError(t, err)
+	m.sources = map[string]StreamSource{"fake:1": newFakeSource("1")}
+	return m
+}
+
+var (
+	liveEvent      = sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventLive}}
+	preparingEvent = sourceEvent{key: "fake:1", event: service.DanmakuEvent{Type: service.DanmakuEventPreparing}}
+)
+
+func TestState_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadState(path)
+	require.NoError(t, err, "a missing state file is an empty state")
+	assert.Empty(t, state.Rooms)
+
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	saved := monitorState{
+		SavedAt: start.Add(time.Hour),
+		Rooms: map[string]roomState{
+			"bilibili:76": {Status: models.StatusLive, SessionStart: start, LastNotice: "live_start", LastNoticeAt: start},
+		},
+	}
+	require.NoError(t, saveState(path, saved))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"status": "live"`)
+
+	state, err = loadState(path)
+	require.NoError(t, err)
+	assert.Equal(t, saved.Rooms, state.Rooms)
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+	_, err = loadState(path)
+	assert.ErrorContains(t, err, "failed to parse state file")
+}
+
+func TestStatusNotice_Names(t *testing.T) {
+	for _, notice := range []statusNotice{noticeLiveStart, noticeLiveEnd, noticeRotationStart, noticeRotationEnd} {
+		parsed, ok := parseStatusNotice(notice.String())
+		assert.True(t, ok)
+		assert.Equal(t, notice, parsed)
+	}
+	_, ok := parseStatusNotice("")
+	assert.False(t, ok)
+}
+
+func TestMonitor_RestoredStateSuppressesNotifications(t *testing.T) {
+	config := Config{StateFile: filepath.Join(t.TempDir(), "state.json")}
+
+	first := newStateMonitor(t, config)
+	first.handleSourceEvent(liveEvent)
+	sent, ok := first.lastNotices["fake:1"]
+	require.True(t, ok)
+	assert.Equal(t, noticeLiveStart, sent.notice)
+
+	// After a restart the room is still live, which is not a change
+	second := newStateMonitor(t, config)
+	assert.Equal(t, models.StatusLive, second.lastStatus["fake:1"])
+	assert.Equal(t, first.sessionStart["fake:1"].Unix(), second.sessionStart["fake:1"].Unix())
+	second.handleSourceEvent(liveEvent)
+	assert.Equal(t, noticeLiveStart, second.lastNotices["fake:1"].notice)
+	assert.Equal(t, sent.at.Unix(), second.lastNotices["fake:1"].at.Unix(), "no new notification")
+
+	// Ending the stream is a real transition
+	second.handleSourceEvent(preparingEvent)
+	assert.Equal(t, noticeLiveEnd, second.lastNotices["fake:1"].notice)
+
+	third := newStateMonitor(t, config)
+	assert.Equal(t, models.StatusOffline, third.lastStatus["fake:1"])
+	assert.Equal(t, noticeLiveEnd, third.lastNotices["fake:1"].notice)
+	assert.NotContains(t, third.sessionStart, "fake:1")
+}
+
+func TestMonitor_CatchUpSummary(t *testing.T) {
+	config := Config{StateFile: filepath.Join(t.TempDir(), "state.json"), CatchUpSummary: true}
+
+	first := newStateMonitor(t, config)
+	first.handleSourceEvent(liveEvent)
+	sent := first.lastNotices["fake:1"]
+
+	// The stream ended while the monitor was stopped
+	second := newStateMonitor(t, config)
+	second.handleSourceEvent(preparingEvent)
+	require.Len(t, second.catchUp, 1)
+	assert.Equal(t, models.StatusLive, second.catchUp[0].From)
+	assert.Equal(t, models.StatusOffline, second.catchUp[0].To)
+	assert.Equal(t, sent.notice, second.lastNotices["fake:1"].notice, "summarized changes are not notified one by one")
+
+	second.flushCatchUp()
+	assert.Empty(t, second.catchUp)
+	assert.Empty(t, second.restored)
+
+	// Later changes notify as usual
+	second.handleSourceEvent(liveEvent)
+	assert.Empty(t, second.catchUp)
+	assert.True(t, second.lastNotices["fake:1"].at.After(sent.at))
+}
+
+func TestMonitor_RestoredNoticeIsNotRepeated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	config := Config{StateFile: path}
+
+	// The last run saw the room go live but stopped before announcing it
+	sentAt := time.Now().Add(-time.Hour)
+	require.NoError(t, saveState(path, monitorState{
+		SavedAt: time.Now(),
+		Rooms: map[string]roomState{
+			"fake:1": {Status: models.StatusLive, SessionStart: sentAt, LastNotice: "live_end", LastNoticeAt: sentAt},
+		},
+	}))
+
+	m := newStateMonitor(t, config)
+	m.handleSourceEvent(preparingEvent)
+	assert.Equal(t, sentAt.Unix(), m.lastNotices["fake:1"].at.Unix(), "the end is not announced twice")
+
+	// Notices that differ from the restored one are sent
+	m.handleSourceEvent(liveEvent)
+	assert.Equal(t, noticeLiveStart, m.lastNotices["fake:1"].notice)
+	assert.False(t, m.lastNotices["fake:1"].restored)
+}
+
+func TestMonitor_PersistStatePrunesRemovedRooms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, saveState(path, monitorState{
+		SavedAt: time.Now(),
+		Rooms: map[string]roomState{
+			"fake:1": {Status: models.StatusOffline},
+			"fake:2": {Status: models.StatusLive},
+		},
+	}))
+
+	// fake:2 is no longer configured
+	m := newStateMonitor(t, Config{StateFile: path})
+	m.handleSourceEvent(liveEvent)
+
+	state, err := loadState(path)
+	require.NoError(t, err)
+	assert.Contains(t, state.Rooms, "fake:1")
+	assert.NotContains(t, state.Rooms, "fake:2")
+}
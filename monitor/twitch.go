@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+// twitchThumbnailSize is the size requested for stream thumbnails
+const twitchThumbnailSize = "1280x720"
+
+// TwitchStreamSource implements StreamSource interface for Twitch platform.
+// The room ID is the channel login.
+type TwitchStreamSource struct {
+	service    *service.TwitchService
+	roomInfo   models.RoomInfo
+	lastStatus bool
+	logger     *logrus.Entry
+}
+
+func init() {
+	RegisterPlatform(Platform{
+		Name: "twitch",
+		Factory: func(room RoomConfig, config Config) (StreamSource, error) {
+			return NewTwitchStreamSource(room.RoomID, config.Platforms.Twitch)
+		},
+		Validate: validateTwitchRoom,
+		Capabilities: Capabilities{
+			PlayURL: true,
+		},
+	})
+}
+
+// validateTwitchRoom checks the room fields a Twitch source needs
+func validateTwitchRoom(room RoomConfig) error {
+	if room.RoomID == "" {
+		return fmt.Errorf("room_id (channel login) is required")
+	}
+	if err := service.ValidateTwitchLogin(room.RoomID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewTwitchStreamSource creates a new Twitch stream source
+func NewTwitchStreamSource(login string, config TwitchConfig) (*TwitchStreamSource, error) {
+	svc, err := service.NewTwitchService(login, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwitchStreamSource{
+		service: svc,
+		roomInfo: models.RoomInfo{
+			Platform: "twitch",
+			RoomID:   svc.Login,
+		},
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "monitor",
+			"platform":  "twitch",
+			"room_id":   svc.Login,
+		}),
+	}, nil
+}
+
+// GetStatus returns the current live status.
+// The same API call also refreshes the title, thumbnail and start time.
+func (t *TwitchStreamSource) GetStatus() (models.LiveStatus, error) {
+	stream, err := t.service.GetTwitchStream()
+	if err != nil {
+		return models.StatusUnknown, fmt.Errorf("failed to get live status: %w", err)
+	}
+
+	isLive := stream != nil
+	if isLive != t.lastStatus {
+		if isLive {
+			t.roomInfo.StartTime = time.Now()
+		}
+		t.lastStatus = isLive
+	}
+	t.roomInfo.IsLive = isLive
+	t.roomInfo.Online = 0
+
+	if stream != nil {
+		t.roomInfo.UID = stream.UserID
+		t.roomInfo.UName = stream.UserName
+		t.roomInfo.Title = stream.Title
+		t.roomInfo.Online = stream.ViewerCount
+		thumbnail := strings.NewReplacer("{width}x{height}", twitchThumbnailSize).Replace(stream.ThumbnailURL)
+		t.roomInfo.UserCover = thumbnail
+		t.roomInfo.Keyframe = thumbnail
+		if !stream.StartedAt.IsZero() {
+			t.roomInfo.StartTime = stream.StartedAt
+		}
+		return models.StatusLive, nil
+	}
+	return models.StatusOffline, nil
+}
+
+// GetRoomInfo returns the room information
+func (t *TwitchStreamSource) GetRoomInfo() models.RoomInfo {
+	if t.roomInfo.UID == "" || t.roomInfo.UName == "" {
+		if user, err := t.service.GetTwitchUser(); err == nil {
+			t.roomInfo.UID = user.ID
+			t.roomInfo.UName = user.DisplayName
+			if t.roomInfo.UserCover == "" {
+				t.roomInfo.UserCover = user.OfflineImageURL
+			}
+		} else {
+			t.logger.WithError(err).Error("Failed to get user info")
+
+			// Fallback: use the login to ensure notifications still work
+			if t.roomInfo.UName == "" {
+				t.roomInfo.UName = t.roomInfo.RoomID
+				t.logger.WithField("anchor_name", t.roomInfo.UName).Warn("Using login as anchor name")
+			}
+		}
+	}
+
+	return t.roomInfo
+}
+
+// GetPlayURL returns the HLS master playlist URL
+func (t *TwitchStreamSource) GetPlayURL() string {
+	playURL, err := t.service.GetTwitchLiveRealURL()
+	if err != nil {
+		t.logger.WithError(err).Error("Failed to get live URL")
+		return ""
+	}
+	return playURL
+}
+
+// StartMsgListener is a no-op, Twitch chat is not supported
+func (t *TwitchStreamSource) StartMsgListener() {
+	t.logger.WithField("room_id", t.roomInfo.RoomID).Debug("Room messages are not supported for Twitch, using polling")
+}
+
+// CloseMsgListener is a no-op, Twitch chat is not supported
+func (t *TwitchStreamSource) CloseMsgListener() {}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTwitchTestConfig starts a local fake of the Twitch endpoints and returns a config pointing at it
+func newTwitchTestConfig(t *testing.T, live *atomic.Bool) TwitchConfig {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	})
+	mux.HandleFunc("/helix/streams", func(w http.ResponseWriter, r *http.Request) {
+		if !live.Load() {
+			_, _ = w.Write([]byte(`{"data":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"user_id":"1001","user_login":"somechannel","user_name":"SomeChannel","type":"live","title":"Hello Twitch","viewer_count":321,"started_at":"2024-03-01T12:00:00Z","thumbnail_url":"https://cdn.example.com/live-{width}x{height}.jpg"}]}`))
+	})
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"1001","login":"somechannel","display_name":"SomeChannel","offline_image_url":"https://cdn.example.com/offline.png"}]}`))
+	})
+	mux.HandleFunc("/gql", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"streamPlaybackAccessToken":{"value":"v","signature":"s"}}}`))
+	})
+	mux.HandleFunc("/api/channel/hls/somechannel.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#EXTM3U\n"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return TwitchConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		HelixURL:     server.URL + "/helix",
+		AuthURL:      server.URL,
+		GQLURL:       server.URL + "/gql",
+		UsherURL:     server.URL,
+	}
+}
+
+func TestNewTwitchStreamSource(t *testing.T) {
+	_, err := NewTwitchStreamSource("somechannel", TwitchConfig{})
+	assert.ErrorContains(t, err, "client_id")
+
+	// The factory reads the credentials from the platforms config
+	source, err := NewStreamSource(RoomConfig{Platform: "twitch", RoomID: "SomeChannel", Enabled: true},
+		Config{Platforms: PlatformsConfig{Twitch: TwitchConfig{ClientID: "id", ClientSecret: "secret"}}})
+	require.NoError(t, err)
+	require.IsType(t, &TwitchStreamSource{}, source)
+	assert.Equal(t, "somechannel", source.(*TwitchStreamSource).roomInfo.RoomID)
+}
+
+func TestTwitchStreamSource(t *testing.T) {
+	var live atomic.Bool
+	source, err := NewTwitchStreamSource("somechannel", newTwitchTestConfig(t, &live))
+	require.NoError(t, err)
+
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusOffline, status)
+
+	info := source.GetRoomInfo()
+	assert.Equal(t, "SomeChannel", info.UName)
+	assert.Equal(t, "1001", info.UID)
+	assert.Equal(t, "https://cdn.example.com/offline.png", info.UserCover)
+
+	live.Store(true)
+	status, err = source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	info = source.GetRoomInfo()
+	assert.Equal(t, "twitch", info.Platform)
+	assert.Equal(t, "Hello Twitch", info.Title)
+	assert.Equal(t, 321, info.Online)
+	assert.Equal(t, "https://cdn.example.com/live-1280x720.jpg", info.UserCover)
+	assert.Equal(t, 2024, info.StartTime.Year())
+	assert.True(t, info.IsLive)
+
+	assert.True(t, strings.Contains(source.GetPlayURL(), "/api/channel/hls/somechannel.m3u8?"))
+}
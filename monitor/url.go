@@ -0,0 +1,148 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/nick3/restreamer_monitor_go/service"
+	"github.com/sirupsen/logrus"
+)
+
+// URLStreamSource implements StreamSource interface for a raw stream URL
+// (HLS, RTMP, FLV, ...) that belongs to no platform.
+// Status is probed from the URL itself and metadata comes from config.
+type URLStreamSource struct {
+	url        string
+	prober     *service.StreamProber
+	roomInfo   models.RoomInfo
+	lastStatus bool
+	logger     *logrus.Entry
+}
+
+func init() {
+	RegisterPlatform(Platform{
+		Name: "url",
+		Factory: func(room RoomConfig, config Config) (StreamSource, error) {
+			return NewURLStreamSourceWithConfig(room, config.Platforms.URL)
+		},
+		Validate: validateURLRoom,
+		Capabilities: Capabilities{
+			PlayURL: true,
+		},
+	})
+}
+
+// validateURLRoom checks the room fields a URL source needs
+func validateURLRoom(room RoomConfig) error {
+	if room.RoomID == "" {
+		return fmt.Errorf("room_id is required to identify the stream")
+	}
+	if room.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	u, err := url.Parse(room.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if !service.SupportedProbeScheme(u.Scheme) || u.Host == "" {
+		return fmt.Errorf("url must be an http, https, rtmp, rtmps or rtsp URL")
+	}
+	return nil
+}
+
+// NewURLStreamSource creates a new stream source for a raw stream URL
+func NewURLStreamSource(room RoomConfig) (*URLStreamSource, error) {
+	return NewURLStreamSourceWithConfig(room, URLConfig{})
+}
+
+// NewURLStreamSourceWithConfig creates a stream source for a raw stream URL
+// that uses the platform settings
+func NewURLStreamSourceWithConfig(room RoomConfig, config URLConfig) (*URLStreamSource, error) {
+	if err := validateURLRoom(room); err != nil {
+		return nil, err
+	}
+	prober := service.NewStreamProber()
+	if err := prober.SetProxy(config.Proxy); err != nil {
+		return nil, err
+	}
+
+	name := room.Name
+	if name == "" {
+		name = room.RoomID
+	}
+
+	return &URLStreamSource{
+		url:    room.URL,
+		prober: prober,
+		roomInfo: models.RoomInfo{
+			Platform:  "url",
+			RoomID:    room.RoomID,
+			UName:     name,
+			Title:     room.Title,
+			UserCover: room.Cover,
+		},
+		// The URL is not logged as it often carries a stream key
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "monitor",
+			"platform":  "url",
+			"room_id":   room.RoomID,
+		}),
+	}, nil
+}
+
+// GetStatus probes the stream URL
+func (u *URLStreamSource) GetStatus() (models.LiveStatus, error) {
+	return u.GetStatusContext(context.Background())
+}
+
+// GetStatusContext is like GetStatus but aborts the probe when ctx is done
+func (u *URLStreamSource) GetStatusContext(ctx context.Context) (models.LiveStatus, error) {
+	isLive, err := u.prober.Probe(ctx, u.url)
+	if err != nil {
+		return models.StatusUnknown, fmt.Errorf("failed to probe stream: %w", err)
+	}
+
+	if isLive != u.lastStatus {
+		u.roomInfo.IsLive = isLive
+		if isLive {
+			u.roomInfo.StartTime = time.Now()
+		}
+		u.lastStatus = isLive
+	}
+
+	if isLive {
+		return models.StatusLive, nil
+	}
+	return models.StatusOffline, nil
+}
+
+// GetRoomInfo returns the room information from config
+func (u *URLStreamSource) GetRoomInfo() models.RoomInfo {
+	return u.roomInfo
+}
+
+// GetRoomInfoContext is like GetRoomInfo, the information needs no requests
+func (u *URLStreamSource) GetRoomInfoContext(ctx context.Context) models.RoomInfo {
+	return u.GetRoomInfo()
+}
+
+// GetPlayURL returns the configured URL unchanged
+func (u *URLStreamSource) GetPlayURL() string {
+	return u.url
+}
+
+// GetPlayURLContext is like GetPlayURL, the URL needs no requests
+func (u *URLStreamSource) GetPlayURLContext(ctx context.Context) string {
+	return u.GetPlayURL()
+}
+
+// StartMsgListener is a no-op, raw streams have no room messages
+func (u *URLStreamSource) StartMsgListener() {}
+
+// CloseMsgListener is a no-op, raw streams have no room messages
+func (u *URLStreamSource) CloseMsgListener() {}
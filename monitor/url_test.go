@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateURLRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		room    RoomConfig
+		wantErr string
+	}{
+		{"hls", RoomConfig{Platform: "url", RoomID: "cam", URL: "https://example.com/live/index.m3u8"}, ""},
+		{"rtmp", RoomConfig{Platform: "url", RoomID: "srs", URL: "rtmp://127.0.0.1/live/main"}, ""},
+		{"missing url", RoomConfig{Platform: "url", RoomID: "srs"}, "url is required"},
+		{"missing room id", RoomConfig{Platform: "url", URL: "rtmp://127.0.0.1/live/main"}, "room_id is required"},
+		{"unsupported scheme", RoomConfig{Platform: "url", RoomID: "x", URL: "ftp://example.com/a"}, "must be an http"},
+		{"no host", RoomConfig{Platform: "url", RoomID: "x", URL: "index.m3u8"}, "must be an http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoom(tt.room)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Relays can use any ingest through the url platform
+	relay := RelayConfig{Name: "r", Source: Source{Platform: "url", RoomID: "srs", URL: "rtmp://127.0.0.1/live/main"}, Enabled: true}
+	assert.NoError(t, ValidateRelay(relay))
+}
+
+func TestURLStreamSource(t *testing.T) {
+	var live atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !live.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:2.0,\nseg1.ts\n"))
+	}))
+	defer server.Close()
+
+	playlist := server.URL + "/live/index.m3u8?key=secret"
+	source, err := NewStreamSource(RoomConfig{
+		Platform: "url",
+		RoomID:   "cam",
+		URL:      playlist,
+		Name:     "Camera",
+		Title:    "Front door",
+		Cover:    "https://example.com/cover.jpg",
+		Enabled:  true,
+	}, Config{})
+	require.NoError(t, err)
+
+	status, err := source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusOffline, status)
+
+	live.Store(true)
+	status, err = source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	info := source.GetRoomInfo()
+	assert.Equal(t, "url", info.Platform)
+	assert.Equal(t, "cam", info.RoomID)
+	assert.Equal(t, "Camera", info.UName)
+	assert.Equal(t, "Front door", info.Title)
+	assert.Equal(t, "https://example.com/cover.jpg", info.UserCover)
+	assert.True(t, info.IsLive)
+
+	assert.Equal(t, playlist, source.GetPlayURL())
+
+	// A server that refuses connections is not publishing
+	server.Close()
+	status, err = source.GetStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusOffline, status)
+}
+
+func TestURLStreamSource_DefaultName(t *testing.T) {
+	source, err := NewURLStreamSource(RoomConfig{Platform: "url", RoomID: "srs", URL: "rtmp://127.0.0.1/live/main"})
+	require.NoError(t, err)
+	assert.Equal(t, "srs", source.GetRoomInfo().UName)
+}
+
+func TestURLStreamSource_StatusContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	source, err := NewURLStreamSource(RoomConfig{Platform: "url", RoomID: "cam", URL: server.URL + "/live.flv"})
+	require.NoError(t, err)
+	var _ ContextSource = source
+
+	// The monitor's check timeout aborts a probe of a stalled server
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	status, err := StatusContext(ctx, source)
+	assert.Error(t, err)
+	assert.Equal(t, models.StatusUnknown, status)
+	assert.Less(t, time.Since(started), time.Second)
+}
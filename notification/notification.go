@@ -223,6 +223,53 @@ func (nm *NotificationManager) SendLiveStatusNotification(roomID string, platfor
 	}
 }
 
+// SendRotationNotification sends a notification when a room starts or stops
+// rotation playback
+func (nm *NotificationManager) SendRotationNotification(roomID string, platform string, rotating bool, roomInfo models.RoomInfo) {
+	if !nm.config.Notifications.MonitorEvents {
+		return
+	}
+
+	if nm.telegramBot == nil {
+		return
+	}
+
+	event := telegram.NotificationEvent{
+		Type:    "monitor",
+		Message: telegram.FormatRotationNotification(roomInfo, rotating),
+		Data: map[string]interface{}{
+			"room_id":   roomID,
+			"platform":  platform,
+			"rotating":  rotating,
+			"room_info": roomInfo,
+		},
+		Timestamp: time.Now(),
+	}
+	nm.telegramBot.SendNotification(event)
+}
+
+// SendCatchUpSummary sends one notification listing the room status changes
+// that happened while the monitor was not running
+func (nm *NotificationManager) SendCatchUpSummary(changes []models.StatusChange) {
+	if !nm.config.Notifications.MonitorEvents || len(changes) == 0 {
+		return
+	}
+
+	if nm.telegramBot == nil {
+		return
+	}
+
+	event := telegram.NotificationEvent{
+		Type:    "monitor",
+		Message: telegram.FormatCatchUpSummary(changes),
+		Data: map[string]interface{}{
+			"changes": changes,
+		},
+		Timestamp: time.Now(),
+	}
+	nm.telegramBot.SendNotification(event)
+}
+
 // SendRelayStatusNotification sends a relay status change notification
 func (nm *NotificationManager) SendRelayStatusNotification(relayName string, status string, details map[string]interface{}) {
 	if !nm.config.Notifications.RelayEvents {
@@ -3,6 +3,7 @@ package notification
 import (
 	"testing"
 
+	"github.com/nick3/restreamer_monitor_go/models"
 	"github.com/nick3/restreamer_monitor_go/telegram"
 	"github.com/stretchr/testify/assert"
 )
@@ -83,6 +84,18 @@ func TestNotificationManager_Notifications(t *testing.T) {
 		})
 	})
 
+	t.Run("rotation notification", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			nm.SendRotationNotification("123", "bilibili", true, models.RoomInfo{RoomID: "123"})
+		})
+	})
+
+	t.Run("catch-up summary", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			nm.SendCatchUpSummary([]models.StatusChange{{RoomInfo: models.RoomInfo{RoomID: "123"}, From: models.StatusOffline, To: models.StatusLive}})
+		})
+	})
+
 	t.Run("relay status notification", func(t *testing.T) {
 		assert.NotPanics(t, func() {
 			nm.SendRelayStatusNotification("test-relay", "started", map[string]interface{}{
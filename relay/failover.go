@@ -0,0 +1,137 @@
+package relay
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+)
+
+// errSourceConnect marks a relay attempt whose source host could not be
+// streamed from, so the next CDN host can be tried
+var errSourceConnect = errors.New("failed to connect to source host")
+
+// errInputFailed marks an FFmpeg exit caused by reading the source rather
+// than by writing to the destination
+var errInputFailed = errors.New("ffmpeg could not read the source")
+
+// connectGracePeriod is how long after starting a relay an FFmpeg failure
+// to read the source is blamed on the source host rather than on the
+// running stream
+var connectGracePeriod = 15 * time.Second
+
+// stderrTailSize is how much of FFmpeg's error output is kept to tell
+// source failures from destination failures
+const stderrTailSize = 8 << 10
+
+// failedHostTTL is how long a failed CDN host is tried after the others
+var failedHostTTL = 10 * time.Minute
+
+// hostFailures remembers which CDN hosts failed recently for each room.
+// It is shared by all relays, since relays of one room use the same hosts.
+type hostFailures struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]time.Time // Room key to host to failure time
+}
+
+// failedHosts is the process-wide record of failed CDN hosts
+var failedHosts = &hostFailures{rooms: make(map[string]map[string]time.Time)}
+
+// mark records that host failed for room
+func (h *hostFailures) mark(room, host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hosts, exists := h.rooms[room]
+	if !exists {
+		hosts = make(map[string]time.Time)
+		h.rooms[room] = hosts
+	}
+	hosts[host] = time.Now()
+}
+
+// failed reports whether host failed for room within failedHostTTL
+func (h *hostFailures) failed(room, host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	failedAt, exists := h.rooms[room][host]
+	if !exists {
+		return false
+	}
+	if time.Since(failedAt) > failedHostTTL {
+		delete(h.rooms[room], host)
+		return false
+	}
+	return true
+}
+
+// order moves candidates on recently failed hosts behind the others,
+// keeping the ranking within both groups. Failed hosts are still tried
+// last, so a room whose hosts all failed keeps relaying.
+func (h *hostFailures) order(room string, candidates []models.StreamCandidate) []models.StreamCandidate {
+	ordered := make([]models.StreamCandidate, 0, len(candidates))
+	var failed []models.StreamCandidate
+	for _, candidate := range candidates {
+		if candidate.Host != "" && h.failed(room, candidate.Host) {
+			failed = append(failed, candidate)
+			continue
+		}
+		ordered = append(ordered, candidate)
+	}
+	return append(ordered, failed...)
+}
+
+// tailBuffer keeps the last stderrTailSize bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write appends p, dropping the oldest bytes beyond stderrTailSize
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = t.buf[len(t.buf)-stderrTailSize:]
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return string(t.buf)
+}
+
+// inputFailure reports whether FFmpeg's error output shows that it could
+// not open or read the source at sourceURL. Destination failures, such as
+// a rejected stream key, are not input failures.
+func inputFailure(stderr, sourceURL string) bool {
+	var host string
+	if u, err := url.Parse(sourceURL); err == nil {
+		host = u.Hostname()
+	}
+
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, "Error opening input"):
+			return true
+		case strings.HasPrefix(line, "[in#") && strings.Contains(line, "Error"):
+			return true
+		case strings.HasPrefix(line, sourceURL+": "):
+			return true
+		case host != "" && strings.Contains(line, "Connection to tcp://"+host+":"):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostFailures_Order(t *testing.T) {
+	hosts := &hostFailures{rooms: make(map[string]map[string]time.Time)}
+	candidates := []models.StreamCandidate{
+		{URL: "a1", Host: "a"},
+		{URL: "b1", Host: "b"},
+		{URL: "a2", Host: "a"},
+		{URL: "c1", Host: "c"},
+	}
+
+	assert.Equal(t, candidates, hosts.order("bilibili/76", candidates))
+
+	// Failed hosts move last and keep their ranking
+	hosts.mark("bilibili/76", "a")
+	ordered := hosts.order("bilibili/76", candidates)
+	var urls []string
+	for _, candidate := range ordered {
+		urls = append(urls, candidate.URL)
+	}
+	assert.Equal(t, []string{"b1", "c1", "a1", "a2"}, urls)
+
+	// Failures are remembered per room
+	assert.False(t, hosts.failed("bilibili/3", "a"))
+}
+
+func TestHostFailures_Expiry(t *testing.T) {
+	originalTTL := failedHostTTL
+	failedHostTTL = 50 * time.Millisecond
+	defer func() { failedHostTTL = originalTTL }()
+
+	hosts := &hostFailures{rooms: make(map[string]map[string]time.Time)}
+	hosts.mark("bilibili/76", "a")
+	assert.True(t, hosts.failed("bilibili/76", "a"))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, hosts.failed("bilibili/76", "a"))
+}
+
+func TestInputFailure(t *testing.T) {
+	source := "https://a.bilivideo.com/live-bvc/live.flv?expires=1"
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{"open input", "[in#0 @ 0x1] Error opening input: Server returned 404 Not Found", true},
+		{"input url", source + ": Server returned 403 Forbidden (access denied)", true},
+		{"demuxing", "[in#0/flv @ 0x1] Error during demuxing: I/O error", true},
+		{"connect", "[tcp @ 0x1] Connection to tcp://a.bilivideo.com:443 failed: Connection refused", true},
+		{"output", "[out#0/flv @ 0x1] Error opening output rtmp://127.0.0.1/live/test: Input/output error", false},
+		{"destination connect", "[tcp @ 0x1] Connection to tcp://127.0.0.1:1935 failed: Connection refused", false},
+		{"nothing", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, inputFailure("Input #0, flv\n"+tt.stderr+"\n", source))
+		})
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var tail tailBuffer
+	tail.Write([]byte(strings.Repeat("a", stderrTailSize)))
+	tail.Write([]byte("end"))
+	assert.Len(t, tail.String(), stderrTailSize)
+	assert.True(t, strings.HasSuffix(tail.String(), "aend"))
+}
@@ -3,7 +3,9 @@ package relay
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -11,7 +13,10 @@ import (
 	"time"
 
 	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
 	"github.com/nick3/restreamer_monitor_go/monitor"
+	"github.com/nick3/restreamer_monitor_go/notification"
+	"github.com/nick3/restreamer_monitor_go/service"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,6 +24,7 @@ import (
 type RelayManager struct {
 	config          monitor.Config
 	relays          map[string]*StreamRelay
+	notificationMgr *notification.NotificationManager // Sends admin alerts such as an expired login
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
@@ -30,6 +36,7 @@ type RelayManager struct {
 type StreamRelay struct {
 	config       monitor.RelayConfig
 	source       monitor.StreamSource
+	proxy        string // HTTP proxy for FFmpeg's input, empty for direct pulls
 	processes    map[string]*exec.Cmd
 	ctx          context.Context
 	cancel       context.CancelFunc
@@ -38,9 +45,29 @@ type StreamRelay struct {
 	lastError    error
 	startTime    time.Time
 	restartCount int
+	// statusFailures counts consecutive source status checks that failed
+	statusFailures int
+	// urlRefreshes counts planned restarts before the source URL expired
+	urlRefreshes int
+	// hostRotations counts switches to another CDN host after a failed connect
+	hostRotations int
 	logger       *logrus.Entry
 }
 
+// sourcePollInterval is how long the relay waits before re-checking a source
+// that is offline or whose status could not be determined
+var sourcePollInterval = 10 * time.Second
+
+// urlRefreshMargin is how long before a source URL expires the relay
+// restarts with a fresh URL
+var urlRefreshMargin = 2 * time.Minute
+
+// roomResolveTimeout bounds the source room lookup when a relay is created
+var roomResolveTimeout = 15 * time.Second
+
+// ffmpegPath is the FFmpeg binary used for relay processes
+var ffmpegPath = "ffmpeg"
+
 // NewRelayManager creates a new relay manager
 func NewRelayManager(configFile string) (*RelayManager, error) {
 	config, err := loadConfig(configFile)
@@ -48,6 +75,11 @@ func NewRelayManager(configFile string) (*RelayManager, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// The shared client is configured once, before any source uses it
+	if err := service.DefaultBilibiliClient().Configure(config.Platforms.Bilibili); err != nil {
+		return nil, fmt.Errorf("failed to configure Bilibili client: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	manager := &RelayManager{
@@ -58,13 +90,20 @@ func NewRelayManager(configFile string) (*RelayManager, error) {
 		logger: logger.GetLogger(map[string]interface{}{"component": "relay", "module": "manager"}),
 	}
 
+	notificationMgr, err := monitor.NewNotificationManager(config)
+	if err != nil {
+		manager.logger.WithError(err).Warn("Failed to create notification manager, continuing without notifications")
+	} else {
+		manager.notificationMgr = notificationMgr
+	}
+
 	// Initialize relay instances
 	for _, relayConfig := range config.Relays {
 		if !relayConfig.Enabled {
 			continue
 		}
 
-		relay, err := NewStreamRelay(relayConfig, ctx)
+		relay, err := newStreamRelay(relayConfig, config, ctx)
 		if err != nil {
 			manager.logger.WithError(err).Errorf("Failed to create relay %s", relayConfig.Name)
 			continue
@@ -78,33 +117,61 @@ func NewRelayManager(configFile string) (*RelayManager, error) {
 
 // NewStreamRelay creates a new stream relay instance
 func NewStreamRelay(config monitor.RelayConfig, parentCtx context.Context) (*StreamRelay, error) {
-	// Create stream source based on platform
-	var source monitor.StreamSource
-	var err error
+	return newStreamRelay(config, monitor.Config{}, parentCtx)
+}
 
-	switch config.Source.Platform {
-	case "bilibili":
-		source, err = monitor.NewBilibiliStreamSource(config.Source.RoomID)
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", config.Source.Platform)
+// newStreamRelay creates a stream relay whose source can use platform-wide settings
+func newStreamRelay(config monitor.RelayConfig, appConfig monitor.Config, parentCtx context.Context) (*StreamRelay, error) {
+	if err := monitor.ValidateRelay(config); err != nil {
+		return nil, err
 	}
 
+	// Resolve references such as live room URLs to the room ID
+	resolveCtx, cancelResolve := context.WithTimeout(parentCtx, roomResolveTimeout)
+	room, resolveErr := monitor.ResolveRoom(resolveCtx, config.Source.RoomConfig(), appConfig)
+	cancelResolve()
+	// Nothing is keyed by a relay's room ID, so a reference the platform
+	// accepts as is, such as a short room ID, still works unresolved
+	config.Source.RoomID = room.RoomID
+
+	// Create stream source through the platform registry
+	source, err := monitor.NewStreamSource(room, appConfig)
 	if err != nil {
+		if resolveErr != nil {
+			return nil, fmt.Errorf("failed to resolve source room: %w", resolveErr)
+		}
 		return nil, fmt.Errorf("failed to create stream source: %w", err)
 	}
 
+	relayLogger := logger.GetLogger(map[string]interface{}{
+		"component": "relay",
+		"module":    config.Name,
+	})
+	if resolveErr != nil {
+		relayLogger.WithError(resolveErr).Warn("Failed to resolve source room, using it as configured")
+	}
+
+	if platform, _ := monitor.LookupPlatform(config.Source.Platform); (config.Quality != "" || config.Codec != "") && !platform.Capabilities.QualitySelection {
+		relayLogger.Warnf("Platform %s does not support quality selection, quality %q and codec %q are not used to pick the source stream", platform.Name, config.Quality, config.Codec)
+	}
+
+	// The relay's own proxy wins over the platform's. Config validation
+	// rejects platform proxies FFmpeg cannot pull through.
+	proxy := config.Proxy
+	if proxy == "" {
+		proxy = appConfig.Platforms.Proxy(config.Source.Platform)
+	}
+
 	ctx, cancel := context.WithCancel(parentCtx)
 
 	return &StreamRelay{
 		config:    config,
 		source:    source,
+		proxy:     proxy,
 		processes: make(map[string]*exec.Cmd),
 		ctx:       ctx,
 		cancel:    cancel,
-		logger: logger.GetLogger(map[string]interface{}{
-			"component": "relay",
-			"module":    config.Name,
-		}),
+		logger:    relayLogger,
 	}, nil
 }
 
@@ -127,6 +194,17 @@ func (rm *RelayManager) Run() error {
 		}(name, relay)
 	}
 
+	// Relays pull with the shared Bilibili login, so it is checked here too
+	if rm.config.Platforms.Bilibili.HasLogin() && rm.hasPlatform("bilibili") {
+		rm.wg.Add(1)
+		go func() {
+			defer rm.wg.Done()
+			monitor.WatchBilibiliSession(rm.ctx, service.DefaultBilibiliClient(), rm.config, func() {
+				monitor.NotifyBilibiliSessionExpired(rm.notificationMgr)
+			})
+		}()
+	}
+
 	// Wait for context cancellation
 	<-rm.ctx.Done()
 
@@ -136,6 +214,16 @@ func (rm *RelayManager) Run() error {
 	return nil
 }
 
+// hasPlatform reports whether any relay pulls from the platform
+func (rm *RelayManager) hasPlatform(name string) bool {
+	for _, relay := range rm.relays {
+		if relay.config.Source.Platform == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Stop stops all relays
 func (rm *RelayManager) Stop() {
 	rm.logger.Info("Stopping relay manager...")
@@ -155,18 +243,20 @@ func (rm *RelayManager) Stop() {
 // Start starts the stream relay
 func (sr *StreamRelay) Start() error {
 	sr.mu.Lock()
-	defer sr.mu.Unlock()
-
 	if sr.isRunning {
+		sr.mu.Unlock()
 		return nil
 	}
 
 	sr.logger.WithFields(logrus.Fields{
 		"relay_name": sr.config.Name,
 		"quality":    sr.config.Quality,
+		"proxy":      service.RedactProxy(sr.proxy),
 	}).Info("Starting relay")
 	sr.startTime = time.Now()
 	sr.isRunning = true
+	// The lock must not be held by the relay loop, which takes it itself
+	sr.mu.Unlock()
 
 	// Main relay loop
 	for {
@@ -179,8 +269,10 @@ func (sr *StreamRelay) Start() error {
 					"relay_name": sr.config.Name,
 					"restart_count": sr.restartCount,
 				}).Error("Relay error")
+				sr.mu.Lock()
 				sr.lastError = err
 				sr.restartCount++
+				sr.mu.Unlock()
 
 				// Wait before restart
 				select {
@@ -197,25 +289,94 @@ func (sr *StreamRelay) Start() error {
 // runRelay runs the actual relay process
 func (sr *StreamRelay) runRelay() error {
 	// Check if source is live
-	if !sr.source.GetStatus() {
+	status, err := monitor.StatusContext(sr.ctx, sr.source)
+	if err != nil || status == models.StatusUnknown {
+		// An unknown status must not be treated as offline or counted as a restart
+		sr.mu.Lock()
+		sr.statusFailures++
+		failures := sr.statusFailures
+		sr.mu.Unlock()
+
+		sr.logger.WithError(err).WithFields(logrus.Fields{
+			"relay_name":           sr.config.Name,
+			"consecutive_failures": failures,
+		}).Warn("Failed to determine source status, retrying")
+		sr.wait(sourcePollInterval)
+		return nil
+	}
+
+	sr.mu.Lock()
+	sr.statusFailures = 0
+	sr.mu.Unlock()
+
+	// Rotation playback is relayed like a live stream unless the relay skips it
+	if status == models.StatusRotation && sr.config.SkipRotation {
+		sr.logger.WithField("relay_name", sr.config.Name).Debug("Source is in rotation playback, waiting for a live stream...")
+		sr.wait(sourcePollInterval)
+		return nil
+	}
+	if status != models.StatusLive && status != models.StatusRotation {
 		sr.logger.WithField("relay_name", sr.config.Name).Debug("Source is not live, waiting...")
-		time.Sleep(10 * time.Second)
+		sr.wait(sourcePollInterval)
 		return nil
 	}
 
-	// Get source stream URL
-	sourceURL := sr.source.GetPlayURL()
-	if sourceURL == "" {
-		return fmt.Errorf("failed to get source stream URL")
+	// Get source streams, trying the next CDN host when one fails to connect
+	streams, err := sr.resolveSources()
+	if err != nil {
+		return err
 	}
 
+	room := sr.roomKey()
+	tried := make(map[string]bool)
+	var lastErr error
+	for _, stream := range streams {
+		if tried[stream.Host] {
+			continue
+		}
+		if lastErr != nil {
+			sr.mu.Lock()
+			sr.hostRotations++
+			sr.mu.Unlock()
+			sr.logger.WithError(lastErr).WithFields(logrus.Fields{
+				"relay_name": sr.config.Name,
+				"host":       stream.Host,
+			}).Warn("Source host failed, rotating to the next host")
+		}
+
+		lastErr = sr.relayStream(stream)
+		if stream.Host == "" || !errors.Is(lastErr, errSourceConnect) {
+			return lastErr
+		}
+		tried[stream.Host] = true
+		failedHosts.mark(room, stream.Host)
+	}
+	return lastErr
+}
+
+// relayStream relays one source stream to all destinations until a process
+// fails, the stream URL is about to expire or the relay is stopped
+func (sr *StreamRelay) relayStream(stream models.StreamCandidate) error {
+	sourceURL := stream.URL
+
 	sr.logger.WithFields(logrus.Fields{
-		"relay_name":  sr.config.Name,
-		"source_url":  sourceURL,
-		"dest_count":  len(sr.config.Destinations),
-		"quality":     sr.config.Quality,
+		"relay_name": sr.config.Name,
+		"source_url": sourceURL,
+		"host":       stream.Host,
+		"dest_count": len(sr.config.Destinations),
+		"quality":    sr.config.Quality,
+		"expiry":     stream.Expiry,
 	}).Info("Got source URL, starting relay processes")
-	
+
+	// Restart with a fresh URL before this one expires
+	refresh, stopRefresh := sr.refreshTimer(stream)
+	defer stopRefresh()
+
+	// Processes of this run are killed together when it ends
+	runCtx, cancelRun := context.WithCancel(sr.ctx)
+	defer cancelRun()
+	started := time.Now()
+
 	// Start relay processes for each destination
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(sr.config.Destinations))
@@ -224,7 +385,7 @@ func (sr *StreamRelay) runRelay() error {
 		wg.Add(1)
 		go func(dest monitor.Destination) {
 			defer wg.Done()
-			if err := sr.startRelayProcess(sourceURL, dest); err != nil {
+			if err := sr.startRelayProcess(runCtx, sourceURL, dest); err != nil {
 				errChan <- fmt.Errorf("destination %s failed: %w", dest.Name, err)
 			}
 		}(dest)
@@ -235,50 +396,159 @@ func (sr *StreamRelay) runRelay() error {
 		wg.Wait()
 		close(errChan)
 	}()
+
+	// stopRun kills this run's processes and waits for them to exit, so
+	// the next run starts cleanly
+	stopRun := func() {
+		cancelRun()
+		sr.stopAllProcesses()
+		for range errChan {
+		}
+	}
 	
-	// Wait for first error or context cancellation
+	// Wait for first error, URL refresh or context cancellation
 	select {
 	case <-sr.ctx.Done():
 		sr.stopAllProcesses()
 		return nil
+	case <-refresh:
+		sr.logger.WithFields(logrus.Fields{
+			"relay_name": sr.config.Name,
+			"expiry":     stream.Expiry,
+		}).Info("Source URL expires soon, restarting relay with a fresh URL")
+		stopRun()
+		sr.mu.Lock()
+		sr.urlRefreshes++
+		sr.mu.Unlock()
+		return nil
 	case err := <-errChan:
-		sr.stopAllProcesses()
+		stopRun()
 		if err != nil {
+			// A relay that fails right away reading the source could not
+			// connect to the source host; destination failures are not its fault
+			if stream.Host != "" && time.Since(started) < connectGracePeriod && errors.Is(err, errInputFailed) {
+				return fmt.Errorf("%w %s: %w", errSourceConnect, stream.Host, err)
+			}
 			return err
 		}
 		return nil
 	}
 }
 
+// roomKey identifies the relay's source room in the failed host record
+func (sr *StreamRelay) roomKey() string {
+	return sr.config.Source.Platform + "/" + sr.config.Source.RoomID
+}
+
+// resolveSources returns the streams to relay, most preferred first.
+// Sources with several stream variants are asked for the best matches of
+// the relay's quality and codec, with recently failed CDN hosts moved
+// last; other sources give a plain URL without expiry.
+func (sr *StreamRelay) resolveSources() ([]models.StreamCandidate, error) {
+	cs, ok := sr.source.(monitor.CandidateSource)
+	if !ok {
+		sourceURL := monitor.PlayURLContext(sr.ctx, sr.source)
+		if sourceURL == "" {
+			return nil, fmt.Errorf("failed to get source stream URL")
+		}
+		return []models.StreamCandidate{{URL: sourceURL}}, nil
+	}
+
+	candidates, err := cs.StreamCandidatesContext(sr.ctx, sr.config.Quality, sr.config.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source streams: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("source returned no streams")
+	}
+
+	candidates = failedHosts.order(sr.roomKey(), candidates)
+	selected := candidates[0]
+	sr.logger.WithFields(logrus.Fields{
+		"relay_name": sr.config.Name,
+		"protocol":   selected.Protocol,
+		"format":     selected.Format,
+		"codec":      selected.Codec,
+		"qn":         selected.Qn,
+		"host":       selected.Host,
+		"candidates": len(candidates),
+	}).Info("Selected source stream")
+	return candidates, nil
+}
+
+// refreshTimer returns a channel that fires urlRefreshMargin before the
+// stream expires, or never for streams without an expiry. A stream that
+// is already inside the margin is refreshed halfway to its expiry.
+func (sr *StreamRelay) refreshTimer(stream models.StreamCandidate) (<-chan time.Time, func()) {
+	if stream.Expiry.IsZero() {
+		return nil, func() {}
+	}
+
+	remaining := time.Until(stream.Expiry)
+	if remaining <= 0 {
+		sr.logger.WithFields(logrus.Fields{
+			"relay_name": sr.config.Name,
+			"expiry":     stream.Expiry,
+		}).Warn("Source URL has already expired")
+		return nil, func() {}
+	}
+
+	delay := remaining - urlRefreshMargin
+	if delay <= 0 {
+		delay = remaining / 2
+	}
+	timer := time.NewTimer(delay)
+	return timer.C, func() { timer.Stop() }
+}
+
+// wait blocks for the given duration or until the relay is stopped
+func (sr *StreamRelay) wait(d time.Duration) {
+	select {
+	case <-sr.ctx.Done():
+	case <-time.After(d):
+	}
+}
+
 // startRelayProcess starts a single relay process to a destination
-func (sr *StreamRelay) startRelayProcess(sourceURL string, dest monitor.Destination) error {
+func (sr *StreamRelay) startRelayProcess(ctx context.Context, sourceURL string, dest monitor.Destination) error {
 	// Build FFmpeg command
 	args := sr.buildFFmpegArgs(sourceURL, dest)
 
-	cmd := exec.CommandContext(sr.ctx, "ffmpeg", args...)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	stderr := &tailBuffer{}
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
 
 	sr.logger.WithFields(logrus.Fields{
 		"relay_name": sr.config.Name,
 		"dest_name":  dest.Name,
 		"dest_url":   dest.URL,
 		"protocol":   dest.Protocol,
-		"args":       strings.Join(args, " "),
+		"args":       sr.redactArgs(args),
 	}).Debug("Starting relay process")
 
-	// Store process for cleanup
+	// Start process and store it for cleanup
 	sr.mu.Lock()
-	sr.processes[dest.Name] = cmd
+	err := cmd.Start()
+	if err == nil {
+		sr.processes[dest.Name] = cmd
+	}
 	sr.mu.Unlock()
-
-	// Start process
-	if err := cmd.Start(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
 	// Wait for process to complete
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	sr.mu.Lock()
+	if sr.processes[dest.Name] == cmd {
+		delete(sr.processes, dest.Name)
+	}
+	sr.mu.Unlock()
+	if err != nil {
+		if inputFailure(stderr.String(), sourceURL) {
+			return fmt.Errorf("ffmpeg process failed: %w: %w", errInputFailed, err)
+		}
 		return fmt.Errorf("ffmpeg process failed: %w", err)
 	}
 
@@ -287,25 +557,15 @@ func (sr *StreamRelay) startRelayProcess(sourceURL string, dest monitor.Destinat
 
 // buildFFmpegArgs builds FFmpeg command arguments
 func (sr *StreamRelay) buildFFmpegArgs(sourceURL string, dest monitor.Destination) []string {
-	args := []string{
+	var args []string
+	if sr.proxy != "" {
+		args = append(args, "-http_proxy", sr.proxy)
+	}
+	args = append(args,
 		"-i", sourceURL,
 		"-c", "copy", // Copy streams without re-encoding
-		"-f", "flv",  // Output format
-	}
-	
-	// Add quality options if specified
-	if sr.config.Quality != "" {
-		switch sr.config.Quality {
-		case "best":
-			// Use best quality available
-		case "worst":
-			args = append(args, "-b:v", "500k")
-		case "720p":
-			args = append(args, "-s", "1280x720", "-b:v", "2000k")
-		case "480p":
-			args = append(args, "-s", "854x480", "-b:v", "1000k")
-		}
-	}
+		"-f", "flv", // Output format
+	)
 	
 	// Add destination-specific options
 	for key, value := range dest.Options {
@@ -318,6 +578,18 @@ func (sr *StreamRelay) buildFFmpegArgs(sourceURL string, dest monitor.Destinatio
 	return args
 }
 
+// redactArgs joins FFmpeg arguments for logging with proxy credentials hidden
+func (sr *StreamRelay) redactArgs(args []string) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if sr.proxy != "" && arg == sr.proxy {
+			arg = service.RedactProxy(arg)
+		}
+		redacted[i] = arg
+	}
+	return strings.Join(redacted, " ")
+}
+
 // stopAllProcesses stops all running processes
 func (sr *StreamRelay) stopAllProcesses() {
 	sr.mu.Lock()
@@ -339,15 +611,17 @@ func (sr *StreamRelay) stopAllProcesses() {
 // Stop stops the stream relay
 func (sr *StreamRelay) Stop() {
 	sr.mu.Lock()
-	defer sr.mu.Unlock()
-
 	if !sr.isRunning {
+		sr.mu.Unlock()
 		return
 	}
 
 	sr.logger.WithField("relay_name", sr.config.Name).Info("Stopping relay")
 	sr.isRunning = false
+	sr.mu.Unlock()
+
 	sr.cancel()
+	// stopAllProcesses takes the lock itself
 	sr.stopAllProcesses()
 }
 
@@ -363,6 +637,9 @@ func (sr *StreamRelay) GetStatus() RelayStatus {
 		LastError:    sr.lastError,
 		RestartCount: sr.restartCount,
 		ProcessCount: len(sr.processes),
+		StatusFailures: sr.statusFailures,
+		URLRefreshes:   sr.urlRefreshes,
+		HostRotations:  sr.hostRotations,
 	}
 }
 
@@ -374,6 +651,12 @@ type RelayStatus struct {
 	LastError    error
 	RestartCount int
 	ProcessCount int
+	// StatusFailures is the number of consecutive failed source status checks
+	StatusFailures int
+	// URLRefreshes is the number of restarts made because the source URL was about to expire
+	URLRefreshes int
+	// HostRotations is the number of times the relay moved to another CDN host
+	HostRotations int
 }
 
 // loadConfig loads configuration from JSON file
@@ -402,5 +685,9 @@ func loadConfig(configFile string) (monitor.Config, error) {
 		return config, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return config, nil
 }
\ No newline at end of file
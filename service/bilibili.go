@@ -1,23 +1,24 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
 	"github.com/sirupsen/logrus"
 )
 
 // BilibiliService provides access to Bilibili live streaming API
 type BilibiliService struct {
 	RoomId string
-	Client *resty.Client
+	Client *resty.Client // Shared with all services using the same BilibiliClient
+	api    *BilibiliClient
 	logger *logrus.Entry
 }
 
@@ -55,22 +56,22 @@ func validateRoomID(roomID string) error {
 	return nil
 }
 
-// NewBilibiliService creates a new BilibiliService instance with proper validation
+// NewBilibiliService creates a new BilibiliService instance with proper validation.
+// All services share the process-wide BilibiliClient.
 func NewBilibiliService(roomId string) (*BilibiliService, error) {
+	return NewBilibiliServiceWithClient(roomId, DefaultBilibiliClient())
+}
+
+// NewBilibiliServiceWithClient creates a BilibiliService that sends its requests through client
+func NewBilibiliServiceWithClient(roomId string, client *BilibiliClient) (*BilibiliService, error) {
 	if err := validateRoomID(roomId); err != nil {
 		return nil, fmt.Errorf("invalid room ID: %w", err)
 	}
 
-	client := resty.New().
-		SetBaseURL(baseURL).
-		SetHeader("User-Agent", userAgent).
-		SetTimeout(requestTimeout).
-		SetRetryCount(maxRetryCount).
-		SetRetryWaitTime(retryWaitTime)
-
 	return &BilibiliService{
-		Client: client,
+		Client: client.HTTP,
 		RoomId: roomId,
+		api:    client,
 		logger: logger.GetLogger(map[string]interface{}{
 			"component": "service",
 			"platform":  "bilibili",
@@ -81,11 +82,14 @@ func NewBilibiliService(roomId string) (*BilibiliService, error) {
 
 // GetBilibiliRealRoomId retrieves the real room ID from Bilibili API
 func (b *BilibiliService) GetBilibiliRealRoomId() (string, error) {
-	resp, err := b.Client.R().
-		SetQueryParams(map[string]string{
-			"id": b.RoomId,
-		}).
-		Get(roomInitURL)
+	return b.GetBilibiliRealRoomIdContext(context.Background())
+}
+
+// GetBilibiliRealRoomIdContext is like GetBilibiliRealRoomId with a context that cancels the request and its retries
+func (b *BilibiliService) GetBilibiliRealRoomIdContext(ctx context.Context) (string, error) {
+	body, err := b.api.getCached(ctx, roomInitURL, map[string]string{
+		"id": b.RoomId,
+	})
 
 	if err != nil {
 		return "", fmt.Errorf("failed to get room info: %w", err)
@@ -99,7 +103,7 @@ func (b *BilibiliService) GetBilibiliRealRoomId() (string, error) {
 		} `json:"data"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -115,16 +119,31 @@ func (b *BilibiliService) GetBilibiliRealRoomId() (string, error) {
 	return strconv.Itoa(data.Data.RoomId), nil
 }
 
+// bilibiliLiveStatus maps a live_status value: 0 offline, 1 live, 2 rotation
+func bilibiliLiveStatus(liveStatus int) models.LiveStatus {
+	switch liveStatus {
+	case 1:
+		return models.StatusLive
+	case 2:
+		return models.StatusRotation
+	default:
+		return models.StatusOffline
+	}
+}
+
 // GetBilibiliLiveStatus retrieves the live status of the room
-func (b *BilibiliService) GetBilibiliLiveStatus() (bool, error) {
-	resp, err := b.Client.R().
-		SetQueryParams(map[string]string{
-			"id": b.RoomId,
-		}).
-		Get(roomInitURL)
+func (b *BilibiliService) GetBilibiliLiveStatus() (models.LiveStatus, error) {
+	return b.GetBilibiliLiveStatusContext(context.Background())
+}
+
+// GetBilibiliLiveStatusContext is like GetBilibiliLiveStatus with a context that cancels the request and its retries
+func (b *BilibiliService) GetBilibiliLiveStatusContext(ctx context.Context) (models.LiveStatus, error) {
+	body, err := b.api.getCached(ctx, roomInitURL, map[string]string{
+		"id": b.RoomId,
+	})
 
 	if err != nil {
-		return false, fmt.Errorf("failed to get live status: %w", err)
+		return models.StatusUnknown, fmt.Errorf("failed to get live status: %w", err)
 	}
 
 	var data struct {
@@ -135,29 +154,25 @@ func (b *BilibiliService) GetBilibiliLiveStatus() (bool, error) {
 		} `json:"data"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &data); err != nil {
-		return false, fmt.Errorf("failed to parse response: %w", err)
+	if err := json.Unmarshal(body, &data); err != nil {
+		return models.StatusUnknown, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if data.Code != 0 {
-		return false, fmt.Errorf("API error (code %d): %s", data.Code, data.Msg)
+		return models.StatusUnknown, fmt.Errorf("API error (code %d): %s", data.Code, data.Msg)
 	}
 
 	if data.Msg == "直播间不存在" {
 		b.logger.WithField("room_id", b.RoomId).Warn("Room does not exist")
-		return false, fmt.Errorf("room %s does not exist", b.RoomId)
+		return models.StatusUnknown, fmt.Errorf("room %s does not exist", b.RoomId)
 	}
 	
-	isLive := data.Data.LiveStatus == 1
-	status := "offline"
-	if isLive {
-		status = "live"
-	}
+	status := bilibiliLiveStatus(data.Data.LiveStatus)
 	b.logger.WithFields(logrus.Fields{
 		"room_id": b.RoomId,
-		"status":  status,
+		"status":  status.String(),
 	}).Info("Room status check completed")
-	return isLive, nil
+	return status, nil
 }
 
 
@@ -167,12 +182,18 @@ func (b *BilibiliService) GetRoomBaseInfo() (*struct {
 	UID   string `json:"uid"`
 	UName string `json:"uname"`
 }, error) {
-	resp, err := b.Client.R().
-		SetQueryParams(map[string]string{
-			"room_ids": b.RoomId,
-			"req_biz":  "space",
-		}).
-		Get("xlive/web-room/v1/index/getRoomBaseInfo")
+	return b.GetRoomBaseInfoContext(context.Background())
+}
+
+// GetRoomBaseInfoContext is like GetRoomBaseInfo with a context that cancels the request and its retries
+func (b *BilibiliService) GetRoomBaseInfoContext(ctx context.Context) (*struct {
+	UID   string `json:"uid"`
+	UName string `json:"uname"`
+}, error) {
+	body, err := b.api.getCached(ctx, roomBaseInfoURL, map[string]string{
+		"room_ids": b.RoomId,
+		"req_biz":  "space",
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get room base info: %w", err)
@@ -192,7 +213,7 @@ func (b *BilibiliService) GetRoomBaseInfo() (*struct {
 		} `json:"data"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -236,11 +257,19 @@ func (b *BilibiliService) GetRoomInfo() (*struct {
 	Keyframe  string    `json:"keyframe"`
 	LiveStart time.Time `json:"live_start"`
 }, error) {
-	resp, err := b.Client.R().
-		SetQueryParams(map[string]string{
-			"room_id": b.RoomId,
-		}).
-		Get("room/v1/Room/get_info")
+	return b.GetRoomInfoContext(context.Background())
+}
+
+// GetRoomInfoContext is like GetRoomInfo with a context that cancels the request and its retries
+func (b *BilibiliService) GetRoomInfoContext(ctx context.Context) (*struct {
+	Title     string    `json:"title"`
+	UserCover string    `json:"user_cover"`
+	Keyframe  string    `json:"keyframe"`
+	LiveStart time.Time `json:"live_start"`
+}, error) {
+	body, err := b.api.getCached(ctx, "room/v1/Room/get_info", map[string]string{
+		"room_id": b.RoomId,
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get room info: %w", err)
@@ -257,7 +286,7 @@ func (b *BilibiliService) GetRoomInfo() (*struct {
 		} `json:"data"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -289,124 +318,21 @@ func (b *BilibiliService) GetRoomInfo() (*struct {
 	}, nil
 }
 
-// GetBilibiliLiveRealURL retrieves the real live stream URLs
+// GetBilibiliLiveRealURL retrieves the live stream URLs, best first
 func (b *BilibiliService) GetBilibiliLiveRealURL(realRoomId string) ([]string, error) {
-	if err := validateRoomID(realRoomId); err != nil {
-		return nil, fmt.Errorf("invalid real room ID: %w", err)
-	}
-
-	// processURL converts FLV URLs to M3U8 format
-	processURL := func(urlStr string) string {
-		u, err := url.Parse(urlStr)
-		if err != nil {
-			b.logger.WithError(err).WithField("url", urlStr).Warn("Failed to parse URL")
-			return urlStr
-		}
-		pathParts := strings.Split(u.Path, "/")
-		if len(pathParts) == 0 {
-			return urlStr
-		}
-		filename := pathParts[len(pathParts)-1]
-		if strings.HasSuffix(filename, ".flv") {
-			filename = strings.TrimSuffix(filename, ".flv")
-			filename += "/index.m3u8"
-			pathParts[len(pathParts)-1] = filename
-			u.Path = strings.Join(pathParts, "/")
-			u.RawQuery = ""
-			return u.String()
-		}
-		return urlStr
-	}
-
-	// Try playUrl API first
-	resp, err := b.Client.R().
-		SetQueryParams(map[string]string{
-			"cid":      realRoomId,
-			"qn":       "10000",
-			"platform": "web",
-		}).
-		Get(playURL)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to get play URL: %w", err)
-	}
-
-	var playData struct {
-		Code int `json:"code"`
-		Msg  string `json:"msg"`
-		Data struct {
-			Durl []struct {
-				URL string `json:"url"`
-			} `json:"durl"`
-		} `json:"data"`
-	}
-	
-	if err := json.Unmarshal(resp.Body(), &playData); err != nil {
-		return nil, fmt.Errorf("failed to parse play URL response: %w", err)
-	}
-
-	if playData.Code != 0 {
-		b.logger.WithFields(logrus.Fields{
-			"code": playData.Code,
-			"msg":  playData.Msg,
-		}).Warn("Play URL API returned error")
-	} else if len(playData.Data.Durl) > 0 {
-		url1 := processURL(playData.Data.Durl[0].URL)
-		url2 := playData.Data.Durl[0].URL
-		return []string{url1, url2}, nil
-	}
-
-	// Fallback to room play info API
-	resp, err = b.Client.R().
-		SetQueryParams(map[string]string{
-			"room_id":    realRoomId,
-			"no_playurl": "0",
-			"mask":       "0",
-			"qn":         "10000",
-			"platform":   "web",
-			"protocol":   "0,1",
-			"format":     "0,1,2",
-			"codec":      "0,1",
-		}).
-		Get(roomPlayInfoURL)
+	return b.GetBilibiliLiveRealURLContext(context.Background(), realRoomId)
+}
 
+// GetBilibiliLiveRealURLContext is like GetBilibiliLiveRealURL with a context that cancels the request and its retries
+func (b *BilibiliService) GetBilibiliLiveRealURLContext(ctx context.Context, realRoomId string) ([]string, error) {
+	candidates, err := b.GetStreamCandidatesContext(ctx, realRoomId, "best", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get room play info: %w", err)
-	}
-
-	var roomData struct {
-		Code int `json:"code"`
-		Msg  string `json:"msg"`
-		Data struct {
-			PlayUrlInfo struct {
-				PlayUrl struct {
-					Durl []struct {
-						URL string `json:"url"`
-					} `json:"durl"`
-				} `json:"playurl"`
-			} `json:"playurl_info"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(resp.Body(), &roomData); err != nil {
-		b.logger.WithFields(logrus.Fields{
-			"error":           err,
-			"response_length": len(resp.Body()),
-		}).Error("Failed to parse room play info response")
-		return nil, fmt.Errorf("failed to parse room play info response: %w", err)
+		return nil, err
 	}
 
-	if roomData.Code != 0 {
-		return nil, fmt.Errorf("room play info API error (code %d): %s", roomData.Code, roomData.Msg)
+	urls := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		urls = append(urls, candidate.URL)
 	}
-
-	if len(roomData.Data.PlayUrlInfo.PlayUrl.Durl) > 0 {
-		urls := make([]string, 0, len(roomData.Data.PlayUrlInfo.PlayUrl.Durl))
-		for _, durl := range roomData.Data.PlayUrlInfo.PlayUrl.Durl {
-			urls = append(urls, processURL(durl.URL))
-		}
-		return urls, nil
-	}
-
-	return nil, fmt.Errorf("no live stream URLs found for room %s", realRoomId)
+	return urls, nil
 }
\ No newline at end of file
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSessionCheckInterval is how often a configured login is verified
+const defaultSessionCheckInterval = time.Hour
+
+// BilibiliCredentials are the cookies of a logged-in Bilibili account
+type BilibiliCredentials struct {
+	SESSDATA string `json:"sessdata"`
+	BiliJct  string `json:"bili_jct,omitempty"`
+	Buvid3   string `json:"buvid3,omitempty"`
+}
+
+// BilibiliSession is the login state reported by the nav endpoint
+type BilibiliSession struct {
+	LoggedIn bool
+	UID      string
+	UName    string
+}
+
+// Cookies returns the credentials as cookies for bilibili.com
+func (c BilibiliCredentials) Cookies() []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, cookie := range []struct{ name, value string }{
+		{"SESSDATA", c.SESSDATA},
+		{"bili_jct", c.BiliJct},
+		{"buvid3", c.Buvid3},
+	} {
+		if cookie.value != "" {
+			cookies = append(cookies, &http.Cookie{Name: cookie.name, Value: cookie.value, Domain: ".bilibili.com", Path: "/"})
+		}
+	}
+	return cookies
+}
+
+// HasLogin reports whether the config provides a login
+func (c BilibiliConfig) HasLogin() bool {
+	return c.Credentials != nil || c.CookieFile != ""
+}
+
+// SessionCheckPeriod returns the interval between login checks
+func (c BilibiliConfig) SessionCheckPeriod() time.Duration {
+	if c.SessionCheckInterval == "" {
+		return defaultSessionCheckInterval
+	}
+	interval, err := time.ParseDuration(c.SessionCheckInterval)
+	if err != nil || interval <= 0 {
+		return defaultSessionCheckInterval
+	}
+	return interval
+}
+
+// Validate checks the login settings
+func (c BilibiliConfig) Validate() error {
+	if c.Credentials != nil && c.CookieFile != "" {
+		return fmt.Errorf("credentials and cookie_file cannot both be set")
+	}
+	if c.Credentials != nil && c.Credentials.SESSDATA == "" {
+		return fmt.Errorf("credentials.sessdata is required")
+	}
+	if c.SessionCheckInterval != "" {
+		if interval, err := time.ParseDuration(c.SessionCheckInterval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid session_check_interval %q", c.SessionCheckInterval)
+		}
+	}
+	if _, err := ParseProxy(c.Proxy); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Cookies returns the login cookies from the credentials or the cookie file,
+// or nil when no login is configured
+func (c BilibiliConfig) Cookies() ([]*http.Cookie, error) {
+	if c.Credentials != nil {
+		return c.Credentials.Cookies(), nil
+	}
+	if c.CookieFile != "" {
+		cookies, err := LoadNetscapeCookies(c.CookieFile, "bilibili.com")
+		if err != nil {
+			return nil, err
+		}
+		if len(cookies) == 0 {
+			return nil, fmt.Errorf("cookie file %s has no bilibili.com cookies", c.CookieFile)
+		}
+		return cookies, nil
+	}
+	return nil, nil
+}
+
+// LoadNetscapeCookies reads the cookies for domain from a Netscape cookie
+// file, as exported by browser extensions, curl or yt-dlp. Expired cookies
+// are skipped.
+func LoadNetscapeCookies(path, domain string) ([]*http.Cookie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cookie file: %w", err)
+	}
+	defer file.Close()
+
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+		if httpOnly {
+			text = strings.TrimPrefix(text, "#HttpOnly_")
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookie file line %d: expected 7 tab-separated fields, got %d", line, len(fields))
+		}
+
+		cookieDomain := fields[0]
+		if strings.TrimPrefix(cookieDomain, ".") != domain && !strings.HasSuffix(cookieDomain, "."+domain) {
+			continue
+		}
+
+		cookie := &http.Cookie{
+			Domain:   cookieDomain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookie file: %w", err)
+	}
+
+	return cookies, nil
+}
+
+// SetCookies replaces the cookies sent with every request
+func (c *BilibiliClient) SetCookies(cookies []*http.Cookie) {
+	c.HTTP.Cookies = nil
+	c.HTTP.SetCookies(cookies)
+}
+
+// CheckSession asks the nav endpoint whether the client's cookies belong to
+// a logged-in account
+func (c *BilibiliClient) CheckSession(ctx context.Context) (BilibiliSession, error) {
+	resp, err := c.HTTP.R().
+		SetContext(ctx).
+		Get(c.Wbi.NavURL)
+	if err != nil {
+		return BilibiliSession{}, fmt.Errorf("failed to get login state: %w", err)
+	}
+
+	var data struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			IsLogin bool   `json:"isLogin"`
+			Mid     int64  `json:"mid"`
+			Uname   string `json:"uname"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return BilibiliSession{}, fmt.Errorf("failed to parse login state: %w", err)
+	}
+
+	// Code -101 means the account is not logged in
+	if data.Code != 0 && data.Code != -101 {
+		return BilibiliSession{}, fmt.Errorf("API error (code %d): %s", data.Code, data.Message)
+	}
+
+	session := BilibiliSession{LoggedIn: data.Data.IsLogin, UName: data.Data.Uname}
+	if data.Data.Mid != 0 {
+		session.UID = strconv.FormatInt(data.Data.Mid, 10)
+	}
+	return session, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadNetscapeCookies(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	content := fmt.Sprintf(`# Netscape HTTP Cookie File
+# This is a generated file! Do not edit.
+
+#HttpOnly_.bilibili.com	TRUE	/	TRUE	%d	SESSDATA	abc%%2C123
+.bilibili.com	TRUE	/	FALSE	%d	bili_jct	csrf-token
+.bilibili.com	TRUE	/	FALSE	0	buvid3	device-id
+.bilibili.com	TRUE	/	FALSE	%d	expired	old
+.example.com	TRUE	/	FALSE	%d	other	value
+`, future, future, past, future)
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	cookies, err := LoadNetscapeCookies(path, "bilibili.com")
+	require.NoError(t, err)
+	require.Len(t, cookies, 3)
+
+	assert.Equal(t, "SESSDATA", cookies[0].Name)
+	assert.Equal(t, "abc%2C123", cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, "bili_jct", cookies[1].Name)
+	assert.Equal(t, "buvid3", cookies[2].Name)
+	assert.True(t, cookies[2].Expires.IsZero(), "session cookies have no expiry")
+
+	t.Run("malformed line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.txt")
+		require.NoError(t, os.WriteFile(path, []byte(".bilibili.com TRUE / FALSE 0 SESSDATA x\n"), 0600))
+		_, err := LoadNetscapeCookies(path, "bilibili.com")
+		assert.ErrorContains(t, err, "line 1")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadNetscapeCookies(filepath.Join(t.TempDir(), "missing.txt"), "bilibili.com")
+		assert.Error(t, err)
+	})
+}
+
+func TestBilibiliConfig_Validate(t *testing.T) {
+	assert.NoError(t, BilibiliConfig{}.Validate())
+	assert.NoError(t, BilibiliConfig{Credentials: &BilibiliCredentials{SESSDATA: "x"}, SessionCheckInterval: "30m"}.Validate())
+	assert.NoError(t, BilibiliConfig{CookieFile: "cookies.txt"}.Validate())
+
+	assert.Error(t, BilibiliConfig{Credentials: &BilibiliCredentials{SESSDATA: "x"}, CookieFile: "cookies.txt"}.Validate())
+	assert.Error(t, BilibiliConfig{Credentials: &BilibiliCredentials{BiliJct: "x"}}.Validate())
+	assert.Error(t, BilibiliConfig{CookieFile: "cookies.txt", SessionCheckInterval: "soon"}.Validate())
+
+	assert.Equal(t, time.Hour, BilibiliConfig{}.SessionCheckPeriod())
+	assert.Equal(t, 30*time.Minute, BilibiliConfig{SessionCheckInterval: "30m"}.SessionCheckPeriod())
+}
+
+func TestBilibiliClient_ConfigureCookies(t *testing.T) {
+	cookies := make(chan []*http.Cookie, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies <- r.Cookies()
+		_, _ = w.Write([]byte(roomInitResponse))
+	}))
+	defer server.Close()
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	require.NoError(t, client.Configure(BilibiliConfig{
+		Credentials: &BilibiliCredentials{SESSDATA: "session", BiliJct: "csrf", Buvid3: "device"},
+	}))
+	// Configuring again replaces the cookies rather than adding to them
+	require.NoError(t, client.Configure(BilibiliConfig{
+		RequestsPerSecond: 10,
+		Credentials:       &BilibiliCredentials{SESSDATA: "session", BiliJct: "csrf", Buvid3: "device"},
+	}))
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+	_, err = svc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+
+	sent := map[string]string{}
+	for _, cookie := range <-cookies {
+		sent[cookie.Name] = cookie.Value
+	}
+	assert.Equal(t, map[string]string{"SESSDATA": "session", "bili_jct": "csrf", "buvid3": "device"}, sent)
+
+	err = client.Configure(BilibiliConfig{CookieFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.ErrorContains(t, err, "failed to load login cookies")
+}
+
+func TestBilibiliClient_ConfigureSameSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(roomInitResponse))
+	}))
+	defer server.Close()
+
+	config := BilibiliConfig{Credentials: &BilibiliCredentials{SESSDATA: "session"}}
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	require.NoError(t, client.Configure(config))
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// Applying the settings in use again leaves the client alone, so it is
+	// safe while requests are made
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			_, _ = svc.GetBilibiliLiveStatus()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, client.Configure(BilibiliConfig{Credentials: &BilibiliCredentials{SESSDATA: "session"}}))
+	}
+	<-done
+}
+
+func TestBilibiliClient_CheckSession(t *testing.T) {
+	var loggedIn atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if loggedIn.Load() {
+			_, _ = w.Write([]byte(`{"code":0,"message":"0","data":{"isLogin":true,"mid":1001,"uname":"Alice"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"isLogin":false}}`))
+	}))
+	defer server.Close()
+
+	client := NewBilibiliClient()
+	client.HTTP.SetRetryCount(0)
+	client.Wbi.NavURL = server.URL
+
+	session, err := client.CheckSession(context.Background())
+	require.NoError(t, err)
+	assert.False(t, session.LoggedIn)
+
+	loggedIn.Store(true)
+	session, err = client.CheckSession(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, BilibiliSession{LoggedIn: true, UID: "1001", UName: "Alice"}, session)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	roomBaseInfoURL = "xlive/web-room/v1/index/getRoomBaseInfo"
+	// batchChunkSize is the number of rooms requested per getRoomBaseInfo call
+	batchChunkSize = 50
+	// batchMaxAge is how long a batch result may answer status checks
+	batchMaxAge = 10 * time.Second
+)
+
+// BilibiliRoomStatus is one room's status and metadata from a batch request
+type BilibiliRoomStatus struct {
+	RoomID     string
+	ShortID    string
+	UID        string
+	UName      string
+	Title      string
+	Cover      string
+	LiveStatus int // 0 offline, 1 live, 2 rotation
+	LiveStart  time.Time
+	Online     int // Viewer count
+}
+
+// IsLive reports whether the room is streaming
+func (s BilibiliRoomStatus) IsLive() bool {
+	return s.LiveStatus == 1
+}
+
+// Status returns the room's live status
+func (s BilibiliRoomStatus) Status() models.LiveStatus {
+	return bilibiliLiveStatus(s.LiveStatus)
+}
+
+// batchEntry is a cached batch result
+type batchEntry struct {
+	status    BilibiliRoomStatus
+	fetchedAt time.Time
+}
+
+// BilibiliBatchPoller fetches the status of many rooms with chunked
+// getRoomBaseInfo requests and keeps the results for the rooms' sources
+type BilibiliBatchPoller struct {
+	Client    *resty.Client
+	ChunkSize int
+	MaxAge    time.Duration
+	logger    *logrus.Entry
+
+	mu      sync.RWMutex
+	results map[string]batchEntry
+}
+
+// NewBilibiliBatchPoller creates a batch poller that sends its requests through client
+func NewBilibiliBatchPoller(client *BilibiliClient) *BilibiliBatchPoller {
+	return &BilibiliBatchPoller{
+		Client:    client.HTTP,
+		ChunkSize: batchChunkSize,
+		MaxAge:    batchMaxAge,
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "service",
+			"platform":  "bilibili",
+		}),
+		results: make(map[string]batchEntry),
+	}
+}
+
+// Refresh fetches the status of the given rooms in chunks. Chunks that fail
+// are logged and reported in the returned error; their rooms keep no fresh
+// result, so their sources fall back to per-room requests.
+func (p *BilibiliBatchPoller) Refresh(ctx context.Context, roomIDs []string) error {
+	chunkSize := p.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = batchChunkSize
+	}
+
+	var errs []error
+	for start := 0; start < len(roomIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(roomIDs) {
+			end = len(roomIDs)
+		}
+		chunk := roomIDs[start:end]
+
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		statuses, err := p.fetchRoomBaseInfo(ctx, chunk)
+		if err != nil {
+			p.logger.WithError(err).WithField("rooms", len(chunk)).Warn("Batch status request failed")
+			errs = append(errs, err)
+			continue
+		}
+
+		now := time.Now()
+		p.mu.Lock()
+		for _, status := range statuses {
+			entry := batchEntry{status: status, fetchedAt: now}
+			p.results[status.RoomID] = entry
+			if status.ShortID != "" {
+				p.results[status.ShortID] = entry
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Lookup returns a room's batch result if it is fresh enough.
+// roomID may be the real or the short room ID.
+func (p *BilibiliBatchPoller) Lookup(roomID string) (BilibiliRoomStatus, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	entry, exists := p.results[roomID]
+	if !exists || time.Since(entry.fetchedAt) > p.MaxAge {
+		return BilibiliRoomStatus{}, false
+	}
+	return entry.status, true
+}
+
+// fetchRoomBaseInfo requests one chunk of rooms
+func (p *BilibiliBatchPoller) fetchRoomBaseInfo(ctx context.Context, roomIDs []string) ([]BilibiliRoomStatus, error) {
+	resp, err := p.Client.R().
+		SetContext(ctx).
+		SetQueryParamsFromValues(map[string][]string{
+			"room_ids": roomIDs,
+			"req_biz":  {"space"},
+		}).
+		Get(roomBaseInfoURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room base info: %w", err)
+	}
+
+	var data struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			ByRoomIds map[string]struct {
+				RoomID     int64  `json:"room_id"`
+				ShortID    int64  `json:"short_id"`
+				UID        int64  `json:"uid"`
+				Uname      string `json:"uname"`
+				Title      string `json:"title"`
+				Cover      string `json:"cover"`
+				LiveStatus int    `json:"live_status"`
+				LiveTime   string `json:"live_time"` // Format: "YYYY-MM-DD HH:mm:ss"
+				Online     int    `json:"online"`
+			} `json:"by_room_ids"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if data.Code != 0 {
+		return nil, fmt.Errorf("API error (code %d): %s", data.Code, data.Msg)
+	}
+
+	statuses := make([]BilibiliRoomStatus, 0, len(data.Data.ByRoomIds))
+	for key, room := range data.Data.ByRoomIds {
+		status := BilibiliRoomStatus{
+			RoomID:     key,
+			UName:      room.Uname,
+			Title:      room.Title,
+			Cover:      room.Cover,
+			LiveStatus: room.LiveStatus,
+			Online:     room.Online,
+		}
+		if room.RoomID != 0 {
+			status.RoomID = strconv.FormatInt(room.RoomID, 10)
+		}
+		if room.ShortID != 0 {
+			status.ShortID = strconv.FormatInt(room.ShortID, 10)
+		}
+		if room.UID != 0 {
+			status.UID = strconv.FormatInt(room.UID, 10)
+		}
+		if room.LiveTime != "" && room.LiveTime != "0000-00-00 00:00:00" {
+			if liveStart, err := time.ParseInLocation("2006-01-02 15:04:05", room.LiveTime, chinaTimeZone); err == nil {
+				status.LiveStart = liveStart
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
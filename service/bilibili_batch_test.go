@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchStandIn serves getRoomBaseInfo for any real room ID. Room 22637261
+// has short ID 76 and is live; room 13 fails the whole request.
+func newBatchStandIn(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/"+roomBaseInfoURL, r.URL.Path)
+		assert.NotEmpty(t, r.URL.Query().Get("w_rid"))
+
+		var rooms []string
+		for _, id := range r.URL.Query()["room_ids"] {
+			if id == "13" {
+				_, _ = w.Write([]byte(`{"code":-400,"msg":"request error","data":null}`))
+				return
+			}
+			switch id {
+			case "76", "22637261":
+				rooms = append(rooms, `"22637261":{"room_id":22637261,"short_id":76,"uid":1001,"uname":"Alice","title":"Live now","cover":"https://i0.hdslb.com/cover.jpg","live_status":1,"live_time":"2024-03-01 20:00:00","online":1234}`)
+			default:
+				rooms = append(rooms, fmt.Sprintf(`"%s":{"room_id":%s,"short_id":0,"uid":2%s,"uname":"User%s","title":"","cover":"","live_status":0,"live_time":"0000-00-00 00:00:00"}`, id, id, id, id))
+			}
+		}
+		fmt.Fprintf(w, `{"code":0,"msg":"ok","data":{"by_room_ids":{%s}}}`, strings.Join(rooms, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestBatchPoller(serverURL string) *BilibiliBatchPoller {
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(serverURL).SetRetryCount(0)
+	primeWbiKeys(client)
+	poller := NewBilibiliBatchPoller(client)
+	return poller
+}
+
+func TestBilibiliBatchPoller_Refresh(t *testing.T) {
+	var requests atomic.Int32
+	server := newBatchStandIn(t, &requests)
+
+	poller := newTestBatchPoller(server.URL)
+	poller.ChunkSize = 2
+
+	require.NoError(t, poller.Refresh(context.Background(), []string{"76", "100", "200", "300"}))
+	assert.Equal(t, int32(2), requests.Load())
+
+	// Short IDs resolve to the same result as the real room ID
+	status, ok := poller.Lookup("76")
+	require.True(t, ok)
+	assert.True(t, status.IsLive())
+	assert.Equal(t, "22637261", status.RoomID)
+	assert.Equal(t, "76", status.ShortID)
+	assert.Equal(t, "1001", status.UID)
+	assert.Equal(t, "Alice", status.UName)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), status.LiveStart.UTC())
+	assert.Equal(t, 1234, status.Online)
+
+	byRealID, ok := poller.Lookup("22637261")
+	require.True(t, ok)
+	assert.Equal(t, status, byRealID)
+
+	for _, id := range []string{"100", "200", "300"} {
+		status, ok := poller.Lookup(id)
+		require.True(t, ok, id)
+		assert.False(t, status.IsLive())
+		assert.True(t, status.LiveStart.IsZero())
+	}
+
+	_, ok = poller.Lookup("400")
+	assert.False(t, ok)
+}
+
+func TestBilibiliBatchPoller_FailedChunk(t *testing.T) {
+	var requests atomic.Int32
+	server := newBatchStandIn(t, &requests)
+
+	poller := newTestBatchPoller(server.URL)
+	poller.ChunkSize = 2
+
+	err := poller.Refresh(context.Background(), []string{"100", "13", "200"})
+	assert.ErrorContains(t, err, "code -400")
+
+	// Rooms in the failed chunk have no result and fall back to per-room calls
+	_, ok := poller.Lookup("100")
+	assert.False(t, ok)
+	_, ok = poller.Lookup("200")
+	assert.True(t, ok)
+}
+
+func TestBilibiliBatchPoller_Expiry(t *testing.T) {
+	var requests atomic.Int32
+	server := newBatchStandIn(t, &requests)
+
+	poller := newTestBatchPoller(server.URL)
+	poller.MaxAge = 50 * time.Millisecond
+
+	require.NoError(t, poller.Refresh(context.Background(), []string{"100"}))
+	_, ok := poller.Lookup("100")
+	assert.True(t, ok)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = poller.Lookup("100")
+	assert.False(t, ok)
+}
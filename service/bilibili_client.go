@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// bilibiliCacheTTL is how long a cached response answers identical requests
+const bilibiliCacheTTL = 5 * time.Second
+
+var (
+	defaultBilibiliClientOnce sync.Once
+	defaultBilibiliClient     *BilibiliClient
+)
+
+// BilibiliClient is an HTTP client for the Bilibili live API that is shared
+// by all rooms. Identical concurrent requests are coalesced into one, and
+// successful responses are cached briefly per endpoint and room.
+type BilibiliClient struct {
+	HTTP     *resty.Client
+	CacheTTL time.Duration
+	Wbi      *WbiSigner
+	// FollowingsURL is the follow list endpoint, which lives on the main API host
+	FollowingsURL string
+
+	group    singleflight.Group
+	mu       sync.Mutex
+	cache    map[string]cachedResponse
+	hits     atomic.Int64
+	misses   atomic.Int64
+	requests atomic.Int64 // Cache misses, whether sent or coalesced
+
+	// Rate limiting and risk-control cool-down, see bilibili_ratelimit.go
+	CoolDownBase time.Duration
+	CoolDownMax  time.Duration
+	limiter      *rate.Limiter
+	riskMu       sync.Mutex
+	coolDown     time.Duration // Length of the last cool-down, doubled on each trigger
+	coolDownEnd  time.Time
+
+	configMu   sync.Mutex
+	configured *BilibiliConfig // Settings applied by the last Configure
+	logger     *logrus.Entry
+}
+
+// BilibiliConfig holds settings shared by all Bilibili rooms
+type BilibiliConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"` // Shared API request rate (default 5)
+	// Credentials or CookieFile (Netscape format) log the client in, which
+	// unlocks higher stream qualities and restricted rooms
+	Credentials          *BilibiliCredentials `json:"credentials,omitempty"`
+	CookieFile           string               `json:"cookie_file,omitempty"`
+	SessionCheckInterval string               `json:"session_check_interval,omitempty"` // How often the login is verified (default 1h)
+	// Proxy is an http, https or socks5 URL for API requests and, unless a
+	// relay sets its own, stream pulls
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// BilibiliClientStats reports how many requests the shared client saved
+type BilibiliClientStats struct {
+	Hits      int64 // Answered from the cache
+	Misses    int64 // Sent to the API
+	Coalesced int64 // Waited for an identical request already in flight
+}
+
+// cachedResponse is a response body kept for CacheTTL
+type cachedResponse struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// NewBilibiliClient creates a client with its own connection pool, cache
+// and rate limiter
+func NewBilibiliClient() *BilibiliClient {
+	client := resty.New().
+		SetBaseURL(baseURL).
+		SetHeader("User-Agent", userAgent).
+		SetTimeout(requestTimeout).
+		SetRetryCount(maxRetryCount).
+		SetRetryWaitTime(retryWaitTime)
+
+	c := &BilibiliClient{
+		HTTP:          client,
+		CacheTTL:      bilibiliCacheTTL,
+		FollowingsURL: followingsURL,
+		cache:         make(map[string]cachedResponse),
+		CoolDownBase:  riskControlBaseCoolDown,
+		CoolDownMax:   riskControlMaxCoolDown,
+		limiter:       rate.NewLimiter(rate.Limit(defaultRequestsPerSecond), burstFor(defaultRequestsPerSecond)),
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "service",
+			"platform":  "bilibili",
+		}),
+	}
+	c.Wbi = NewWbiSigner(client, c.logger)
+	client.OnBeforeRequest(c.beforeRequest)
+	client.OnBeforeRequest(c.signRequest)
+	client.OnAfterResponse(c.afterResponse)
+	return c
+}
+
+// DefaultBilibiliClient returns the process-wide client used by all Bilibili services
+func DefaultBilibiliClient() *BilibiliClient {
+	defaultBilibiliClientOnce.Do(func() {
+		defaultBilibiliClient = NewBilibiliClient()
+	})
+	return defaultBilibiliClient
+}
+
+// Configure applies platform settings to the client. It must run before
+// the client is used, since the proxy and cookies are not safe to change
+// while requests are made; applying the settings already in use again is
+// a no-op, so it does not race with a monitor that is running.
+func (c *BilibiliClient) Configure(config BilibiliConfig) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	if c.configured != nil && reflect.DeepEqual(*c.configured, config) {
+		return nil
+	}
+
+	c.SetRequestsPerSecond(config.RequestsPerSecond)
+
+	if err := applyProxy(c.HTTP, config.Proxy); err != nil {
+		return err
+	}
+	if config.Proxy != "" {
+		c.logger.WithField("proxy", RedactProxy(config.Proxy)).Debug("Using proxy for API requests")
+	}
+
+	cookies, err := config.Cookies()
+	if err != nil {
+		return fmt.Errorf("failed to load login cookies: %w", err)
+	}
+	if cookies != nil {
+		c.SetCookies(cookies)
+	}
+	c.configured = &config
+	return nil
+}
+
+// Stats returns the cache and coalescing counters
+func (c *BilibiliClient) Stats() BilibiliClientStats {
+	misses := c.misses.Load()
+	return BilibiliClientStats{
+		Hits:      c.hits.Load(),
+		Misses:    misses,
+		Coalesced: c.requests.Load() - misses,
+	}
+}
+
+// getCached performs a GET request, sharing the result with identical
+// requests in flight and caching successful responses for CacheTTL.
+// Responses carrying an API error code are returned but never cached.
+func (c *BilibiliClient) getCached(ctx context.Context, endpoint string, params map[string]string) ([]byte, error) {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	key := endpoint + "?" + query.Encode()
+
+	c.mu.Lock()
+	cached, exists := c.cache[key]
+	if exists && time.Now().After(cached.expiresAt) {
+		delete(c.cache, key)
+		exists = false
+	}
+	c.mu.Unlock()
+
+	if exists {
+		c.hits.Add(1)
+		return cached.body, nil
+	}
+
+	c.requests.Add(1)
+	results := c.group.DoChan(key, func() (interface{}, error) {
+		c.misses.Add(1)
+
+		// The request runs under the first caller's context; every caller
+		// still gives up on its own context below
+		resp, err := c.HTTP.R().
+			SetContext(ctx).
+			SetQueryParams(params).
+			Get(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", resp.StatusCode())
+		}
+
+		body := resp.Body()
+		if c.CacheTTL > 0 && apiSucceeded(body) {
+			c.mu.Lock()
+			c.cache[key] = cachedResponse{body: body, expiresAt: time.Now().Add(c.CacheTTL)}
+			c.mu.Unlock()
+		}
+		return body, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]byte), nil
+	}
+}
+
+// apiSucceeded reports whether a response body carries code 0
+func apiSucceeded(body []byte) bool {
+	var envelope struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Code == nil {
+		return false
+	}
+	return *envelope.Code == 0
+}
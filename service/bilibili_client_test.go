@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const roomInitResponse = `{"code":0,"msg":"ok","data":{"room_id":22637261,"short_id":76,"live_status":1}}`
+
+// newRoomInitStandIn serves room_init and counts the requests that reach it
+func newRoomInitStandIn(t *testing.T, requests *atomic.Int32, handler http.HandlerFunc) *BilibiliClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/"+roomInitURL, r.URL.Path)
+		if handler != nil {
+			handler(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(roomInitResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	return client
+}
+
+func TestDefaultBilibiliClient(t *testing.T) {
+	assert.Same(t, DefaultBilibiliClient(), DefaultBilibiliClient())
+
+	svc, err := NewBilibiliService("76")
+	require.NoError(t, err)
+	assert.Same(t, DefaultBilibiliClient().HTTP, svc.Client)
+}
+
+func TestBilibiliClient_Cache(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, nil)
+
+	// The relay and the monitor build separate services for the same room
+	monitorSvc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+	relaySvc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	status, err := monitorSvc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	realRoomID, err := relaySvc.GetBilibiliRealRoomId()
+	require.NoError(t, err)
+	assert.Equal(t, "22637261", realRoomID)
+
+	status, err = relaySvc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLive, status)
+
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, BilibiliClientStats{Hits: 2, Misses: 1}, client.Stats())
+
+	// Other rooms are cached separately
+	otherSvc, err := NewBilibiliServiceWithClient("3", client)
+	require.NoError(t, err)
+	_, err = otherSvc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestBilibiliService_LiveStatusValues(t *testing.T) {
+	for liveStatus, want := range map[int]models.LiveStatus{
+		0: models.StatusOffline,
+		1: models.StatusLive,
+		2: models.StatusRotation,
+	} {
+		var requests atomic.Int32
+		client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"code":0,"msg":"ok","data":{"room_id":22637261,"short_id":76,"live_status":%d}}`, liveStatus)
+		})
+
+		svc, err := NewBilibiliServiceWithClient("76", client)
+		require.NoError(t, err)
+		status, err := svc.GetBilibiliLiveStatus()
+		require.NoError(t, err)
+		assert.Equal(t, want, status, "live_status %d", liveStatus)
+	}
+}
+
+func TestBilibiliClient_CacheExpiry(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, nil)
+	client.CacheTTL = 50 * time.Millisecond
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	_, err = svc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = svc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, BilibiliClientStats{Hits: 0, Misses: 2}, client.Stats())
+}
+
+func TestBilibiliClient_FailuresAreNotCached(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	_, err = svc.GetBilibiliLiveStatus()
+	assert.ErrorContains(t, err, "status 503")
+	_, err = svc.GetBilibiliLiveStatus()
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestBilibiliClient_APIErrorsAreNotCached(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":60004,"message":"直播间不存在","data":null}`))
+	})
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	_, err = svc.GetBilibiliLiveStatus()
+	assert.Error(t, err)
+	_, err = svc.GetBilibiliLiveStatus()
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestBilibiliClient_Coalescing(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(roomInitResponse))
+	})
+
+	const callers = 5
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		svc, err := NewBilibiliServiceWithClient("76", client)
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := svc.GetBilibiliLiveStatus()
+			assert.NoError(t, err)
+			assert.Equal(t, models.StatusLive, status)
+		}()
+	}
+
+	// Wait until every caller has joined the request in flight
+	assert.Eventually(t, func() bool {
+		stats := client.Stats()
+		return stats.Misses == 1 && stats.Coalesced == callers-1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestBilibiliClient_CallerContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// A caller waiting on a shared request still honours its own deadline
+	go func() { _, _ = svc.GetBilibiliRealRoomId() }()
+	assert.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = svc.GetBilibiliLiveStatusContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
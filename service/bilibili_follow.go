@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	followingsURL     = "https://api.bilibili.com/x/relation/followings"
+	statusByUIDsURL   = "room/v1/Room/get_status_info_by_uids"
+	followingsPerPage = 50
+)
+
+// Follow list API codes that end paging without failing the sync
+const (
+	followingsPrivateCode   = 22115 // The user hides their follow list
+	followingsPageLimitCode = 22007 // Only the first pages are visible to other users
+)
+
+// BilibiliFollowedRoom is the live room of a streamer a user follows
+type BilibiliFollowedRoom struct {
+	UID    string
+	UName  string
+	RoomID string
+}
+
+// FollowedRooms returns the live rooms of the streamers uid follows, in
+// follow list order. Followed users without a live room are left out.
+// cookie is sent as the Cookie header of the follow list requests; it is
+// needed when the follow list is private or longer than the visible pages.
+func (c *BilibiliClient) FollowedRooms(ctx context.Context, uid, cookie string) ([]BilibiliFollowedRoom, error) {
+	if err := validateRoomID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID %q: %w", uid, err)
+	}
+
+	var rooms []BilibiliFollowedRoom
+	for page := 1; ; page++ {
+		uids, more, err := c.followings(ctx, uid, cookie, page)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(uids) > 0 {
+			byUID, err := c.roomsByUIDs(ctx, uids)
+			if err != nil {
+				return nil, err
+			}
+			for _, followed := range uids {
+				if room, ok := byUID[followed]; ok {
+					rooms = append(rooms, room)
+				}
+			}
+		}
+
+		if !more {
+			return rooms, nil
+		}
+	}
+}
+
+// followings returns the UIDs on one page of a user's follow list and
+// whether another page follows
+func (c *BilibiliClient) followings(ctx context.Context, uid, cookie string, page int) ([]string, bool, error) {
+	req := c.HTTP.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"vmid":  uid,
+			"pn":    strconv.Itoa(page),
+			"ps":    strconv.Itoa(followingsPerPage),
+			"order": "desc",
+		})
+	if cookie != "" {
+		req.SetHeader("Cookie", cookie)
+	}
+
+	resp, err := req.Get(c.FollowingsURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get follow list of user %s: %w", uid, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to get follow list of user %s: unexpected status %d", uid, resp.StatusCode())
+	}
+
+	var data struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			List []struct {
+				Mid int64 `json:"mid"`
+			} `json:"list"`
+			Total int `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, false, fmt.Errorf("failed to parse follow list: %w", err)
+	}
+
+	switch data.Code {
+	case 0:
+	case followingsPrivateCode:
+		return nil, false, fmt.Errorf("follow list of user %s is private, set a cookie of the account", uid)
+	case followingsPageLimitCode:
+		if page > 1 {
+			c.logger.WithField("uid", uid).Warn("Only the first pages of the follow list are visible, set a cookie of the account to sync all follows")
+			return nil, false, nil
+		}
+		fallthrough
+	default:
+		return nil, false, fmt.Errorf("API error (code %d): %s", data.Code, data.Message)
+	}
+
+	uids := make([]string, 0, len(data.Data.List))
+	for _, user := range data.Data.List {
+		uids = append(uids, strconv.FormatInt(user.Mid, 10))
+	}
+	more := len(uids) > 0 && page*followingsPerPage < data.Data.Total
+	return uids, more, nil
+}
+
+// roomsByUIDs returns the live rooms of users, keyed by UID. Users without
+// a live room are missing from the result.
+func (c *BilibiliClient) roomsByUIDs(ctx context.Context, uids []string) (map[string]BilibiliFollowedRoom, error) {
+	resp, err := c.HTTP.R().
+		SetContext(ctx).
+		SetQueryParamsFromValues(map[string][]string{
+			"uids[]": uids,
+		}).
+		Get(statusByUIDsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live rooms of followed users: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("failed to get live rooms of followed users: unexpected status %d", resp.StatusCode())
+	}
+
+	var data struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		// An empty result is an empty array rather than an object
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse live rooms: %w", err)
+	}
+	if data.Code != 0 {
+		return nil, fmt.Errorf("API error (code %d): %s", data.Code, data.Message)
+	}
+
+	var users map[string]struct {
+		UName  string `json:"uname"`
+		RoomID int64  `json:"room_id"`
+	}
+	if len(data.Data) > 0 && data.Data[0] == '{' {
+		if err := json.Unmarshal(data.Data, &users); err != nil {
+			return nil, fmt.Errorf("failed to parse live rooms: %w", err)
+		}
+	}
+
+	rooms := make(map[string]BilibiliFollowedRoom, len(users))
+	for uid, user := range users {
+		if user.RoomID == 0 {
+			continue
+		}
+		rooms[uid] = BilibiliFollowedRoom{
+			UID:    uid,
+			UName:  user.UName,
+			RoomID: strconv.FormatInt(user.RoomID, 10),
+		}
+	}
+	return rooms, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFollowStandIn serves a follow list of the given UIDs in pages of
+// followingsPerPage and a live room for every UID in rooms
+func newFollowStandIn(t *testing.T, follows []int, rooms map[int]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x/relation/followings":
+			assert.Equal(t, "1001", r.URL.Query().Get("vmid"))
+			var page int
+			fmt.Sscan(r.URL.Query().Get("pn"), &page)
+
+			var list []string
+			for i := (page - 1) * followingsPerPage; i < page*followingsPerPage && i < len(follows); i++ {
+				list = append(list, fmt.Sprintf(`{"mid":%d,"uname":"User%d"}`, follows[i], follows[i]))
+			}
+			fmt.Fprintf(w, `{"code":0,"message":"0","data":{"list":[%s],"total":%d}}`, strings.Join(list, ","), len(follows))
+		case "/" + statusByUIDsURL:
+			var users []string
+			for _, uid := range r.URL.Query()["uids[]"] {
+				var id int
+				fmt.Sscan(uid, &id)
+				if roomID, ok := rooms[id]; ok {
+					users = append(users, fmt.Sprintf(`"%d":{"uid":%d,"uname":"User%d","room_id":%s,"live_status":0}`, id, id, id, roomID))
+				}
+			}
+			if len(users) == 0 {
+				_, _ = w.Write([]byte(`{"code":0,"msg":"success","data":[]}`))
+				return
+			}
+			fmt.Fprintf(w, `{"code":0,"msg":"success","data":{%s}}`, strings.Join(users, ","))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestFollowClient(serverURL string) *BilibiliClient {
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(serverURL).SetRetryCount(0)
+	client.FollowingsURL = serverURL + "/x/relation/followings"
+	return client
+}
+
+func TestBilibiliClient_FollowedRooms(t *testing.T) {
+	// Two pages of follows, of which only some have a live room
+	var follows []int
+	for uid := 1; uid <= followingsPerPage+10; uid++ {
+		follows = append(follows, uid)
+	}
+	server := newFollowStandIn(t, follows, map[int]string{3: "300", 55: "5500"})
+	client := newTestFollowClient(server.URL)
+
+	rooms, err := client.FollowedRooms(context.Background(), "1001", "")
+	require.NoError(t, err)
+	assert.Equal(t, []BilibiliFollowedRoom{
+		{UID: "3", UName: "User3", RoomID: "300"},
+		{UID: "55", UName: "User55", RoomID: "5500"},
+	}, rooms)
+
+	// A page without live rooms answers with an empty array
+	server = newFollowStandIn(t, []int{1, 2}, nil)
+	rooms, err = newTestFollowClient(server.URL).FollowedRooms(context.Background(), "1001", "")
+	require.NoError(t, err)
+	assert.Empty(t, rooms)
+}
+
+func TestBilibiliClient_FollowedRoomsErrors(t *testing.T) {
+	var cookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie = r.Header.Get("Cookie")
+		if r.URL.Query().Get("pn") == "1" {
+			_, _ = w.Write([]byte(`{"code":22115,"message":"用户已设置隐私，无法查看"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":-400,"message":"请求错误"}`))
+	}))
+	defer server.Close()
+	client := newTestFollowClient(server.URL)
+
+	_, err := client.FollowedRooms(context.Background(), "1001", "SESSDATA=secret")
+	assert.ErrorContains(t, err, "follow list of user 1001 is private")
+	assert.Equal(t, "SESSDATA=secret", cookie)
+
+	_, err = client.FollowedRooms(context.Background(), "space.bilibili.com/1001", "")
+	assert.ErrorContains(t, err, "invalid UID")
+}
+
+func TestBilibiliClient_FollowedRoomsPageLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/"+statusByUIDsURL:
+			_, _ = w.Write([]byte(`{"code":0,"data":{"7":{"uname":"Alice","room_id":700}}}`))
+		case r.URL.Query().Get("pn") == "1":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"list":[{"mid":7}],"total":500}}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":22007,"message":"访问超过5页"}`))
+		}
+	}))
+	defer server.Close()
+
+	// Pages beyond the visible ones end the list instead of failing the sync
+	rooms, err := newTestFollowClient(server.URL).FollowedRooms(context.Background(), "1001", "")
+	require.NoError(t, err)
+	assert.Equal(t, []BilibiliFollowedRoom{{UID: "7", UName: "Alice", RoomID: "700"}}, rooms)
+}
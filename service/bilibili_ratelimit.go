@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// defaultRequestsPerSecond is the request rate shared by all Bilibili rooms
+	defaultRequestsPerSecond = 5
+	// riskControlBaseCoolDown is the first pause after a risk-control response
+	riskControlBaseCoolDown = 30 * time.Second
+	// riskControlMaxCoolDown caps the doubling cool-down
+	riskControlMaxCoolDown = 10 * time.Minute
+)
+
+// ErrRiskControl is returned when Bilibili's risk control rejects a request,
+// and for every request skipped during the cool-down that follows
+var ErrRiskControl = errors.New("bilibili risk control")
+
+// riskControlCodes are the API codes Bilibili answers with when it blocks a client
+var riskControlCodes = map[int]bool{
+	-352: true,
+	-412: true,
+}
+
+// burstFor returns the token bucket size for a request rate
+func burstFor(rps float64) int {
+	return int(math.Max(1, math.Ceil(rps)))
+}
+
+// SetRequestsPerSecond changes the shared request rate. Zero or a negative
+// value restores the default.
+func (c *BilibiliClient) SetRequestsPerSecond(rps float64) {
+	if rps <= 0 {
+		rps = defaultRequestsPerSecond
+	}
+	c.limiter.SetLimit(rate.Limit(rps))
+	c.limiter.SetBurst(burstFor(rps))
+}
+
+// CoolDownRemaining returns how long requests are still paused after a
+// risk-control response, or zero
+func (c *BilibiliClient) CoolDownRemaining() time.Duration {
+	c.riskMu.Lock()
+	defer c.riskMu.Unlock()
+
+	if remaining := time.Until(c.coolDownEnd); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// beforeRequest fails fast during a cool-down and otherwise waits for a
+// token. Errors returned here are never retried by resty.
+func (c *BilibiliClient) beforeRequest(_ *resty.Client, req *resty.Request) error {
+	if remaining := c.CoolDownRemaining(); remaining > 0 {
+		return fmt.Errorf("%w: requests paused for %v", ErrRiskControl, remaining.Round(time.Second))
+	}
+	return c.waitForToken(req.Context())
+}
+
+// waitForToken blocks until the limiter allows a request or ctx is done
+func (c *BilibiliClient) waitForToken(ctx context.Context) error {
+	reservation := c.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// afterResponse detects risk-control responses and starts a cool-down.
+// Any other response ends the backoff.
+func (c *BilibiliClient) afterResponse(_ *resty.Client, resp *resty.Response) error {
+	code, blocked := riskControlResponse(resp)
+	if !blocked {
+		if resp.StatusCode() < http.StatusBadRequest {
+			c.riskMu.Lock()
+			c.coolDown = 0
+			c.riskMu.Unlock()
+		}
+		return nil
+	}
+
+	c.invalidateRejectedKeys(resp, code)
+	coolDown := c.startCoolDown()
+	c.logger.WithFields(logrus.Fields{
+		"status":    resp.StatusCode(),
+		"code":      code,
+		"cool_down": coolDown,
+	}).Warn("Risk control triggered, pausing Bilibili requests")
+
+	return fmt.Errorf("%w: status %d, code %d", ErrRiskControl, resp.StatusCode(), code)
+}
+
+// startCoolDown pauses all requests, doubling the pause on each trigger up
+// to CoolDownMax. Responses to requests already in flight during a pause do
+// not extend it.
+func (c *BilibiliClient) startCoolDown() time.Duration {
+	c.riskMu.Lock()
+	defer c.riskMu.Unlock()
+
+	if remaining := time.Until(c.coolDownEnd); remaining > 0 {
+		return remaining
+	}
+
+	if c.coolDown == 0 {
+		c.coolDown = c.CoolDownBase
+	} else {
+		c.coolDown *= 2
+	}
+	if c.coolDown > c.CoolDownMax {
+		c.coolDown = c.CoolDownMax
+	}
+	c.coolDownEnd = time.Now().Add(c.coolDown)
+	return c.coolDown
+}
+
+// riskControlResponse reports whether a response is a risk-control rejection,
+// either HTTP 412 or one of the risk-control API codes
+func riskControlResponse(resp *resty.Response) (int, bool) {
+	var data struct {
+		Code int `json:"code"`
+	}
+	_ = json.Unmarshal(resp.Body(), &data)
+
+	if resp.StatusCode() == http.StatusPreconditionFailed {
+		return data.Code, true
+	}
+	return data.Code, riskControlCodes[data.Code]
+}
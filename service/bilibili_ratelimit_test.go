@@ -0,0 +1,114 @@
+package service
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBilibiliClient_RiskControl412(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+	// Risk-control responses must not be retried
+	client.HTTP.SetRetryCount(3).SetRetryWaitTime(time.Millisecond)
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	_, err = svc.GetBilibiliLiveStatus()
+	assert.ErrorIs(t, err, ErrRiskControl)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Greater(t, client.CoolDownRemaining(), 25*time.Second)
+
+	// The cool-down covers every room and skips the network entirely
+	other, err := NewBilibiliServiceWithClient("3", client)
+	require.NoError(t, err)
+	_, err = other.GetBilibiliRealRoomId()
+	assert.ErrorIs(t, err, ErrRiskControl)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestBilibiliClient_RiskControlCodes(t *testing.T) {
+	for _, code := range []string{"-352", "-412"} {
+		t.Run(code, func(t *testing.T) {
+			var requests atomic.Int32
+			client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"code":` + code + `,"message":"blocked"}`))
+			})
+
+			svc, err := NewBilibiliServiceWithClient("76", client)
+			require.NoError(t, err)
+
+			_, err = svc.GetBilibiliLiveStatus()
+			assert.ErrorIs(t, err, ErrRiskControl)
+			assert.Positive(t, client.CoolDownRemaining())
+		})
+	}
+}
+
+func TestBilibiliClient_CoolDownBackoff(t *testing.T) {
+	var blocked atomic.Bool
+	blocked.Store(true)
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, func(w http.ResponseWriter, r *http.Request) {
+		if blocked.Load() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		_, _ = w.Write([]byte(roomInitResponse))
+	})
+	client.CacheTTL = 0
+	client.CoolDownBase = 40 * time.Millisecond
+	client.CoolDownMax = 100 * time.Millisecond
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// Each trigger after a cool-down doubles the pause up to the maximum
+	for _, want := range []time.Duration{40, 80, 100, 100} {
+		_, err = svc.GetBilibiliLiveStatus()
+		require.ErrorIs(t, err, ErrRiskControl)
+		remaining := client.CoolDownRemaining()
+		assert.LessOrEqual(t, remaining, want*time.Millisecond)
+		assert.Greater(t, remaining, want*time.Millisecond/2)
+		time.Sleep(remaining + 10*time.Millisecond)
+	}
+
+	// A successful response resets the backoff
+	blocked.Store(false)
+	_, err = svc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+
+	blocked.Store(true)
+	_, err = svc.GetBilibiliLiveStatus()
+	require.ErrorIs(t, err, ErrRiskControl)
+	assert.LessOrEqual(t, client.CoolDownRemaining(), 40*time.Millisecond)
+}
+
+func TestBilibiliClient_RateLimit(t *testing.T) {
+	var requests atomic.Int32
+	client := newRoomInitStandIn(t, &requests, nil)
+	client.CacheTTL = 0
+	client.Configure(BilibiliConfig{RequestsPerSecond: 20})
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// Requests beyond the tokens in the bucket are spaced at 20 per second
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		_, err := svc.GetBilibiliLiveStatus()
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+
+	assert.Equal(t, int32(25), requests.Load())
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+}
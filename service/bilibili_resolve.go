@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const roomInfoOldURL = "room/v1/Room/getRoomInfoOld"
+
+// Kinds of Bilibili room references
+const (
+	RoomRefRoomID = "room" // A real or short room ID
+	RoomRefUID    = "uid"  // A streamer's user ID
+)
+
+var digitsPattern = regexp.MustCompile(`^\d+$`)
+
+// BilibiliRoomRef is a parsed room reference from the config
+type BilibiliRoomRef struct {
+	Kind string
+	ID   string
+}
+
+// ParseBilibiliRoomRef parses a room ID, short ID, live room URL such as
+// https://live.bilibili.com/12345?..., space URL such as
+// https://space.bilibili.com/67890 or UID written as "uid:67890"
+func ParseBilibiliRoomRef(ref string) (BilibiliRoomRef, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return BilibiliRoomRef{}, fmt.Errorf("room reference cannot be empty")
+	}
+
+	if digitsPattern.MatchString(ref) {
+		return newRoomRef(RoomRefRoomID, ref)
+	}
+	if len(ref) > 4 && strings.EqualFold(ref[:4], "uid:") {
+		return newRoomRef(RoomRefUID, strings.TrimSpace(ref[4:]))
+	}
+
+	raw := ref
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return BilibiliRoomRef{}, fmt.Errorf("invalid room reference %q: %w", ref, err)
+	}
+
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	switch strings.ToLower(u.Hostname()) {
+	case "live.bilibili.com", "m.live.bilibili.com", "www.live.bilibili.com":
+		// The room ID is the last path segment, e.g. /12345, /h5/12345 or /blanc/12345
+		if len(segments) > 0 {
+			return newRoomRef(RoomRefRoomID, segments[len(segments)-1])
+		}
+	case "space.bilibili.com", "m.bilibili.com":
+		// Space URLs start with the UID, e.g. /67890/dynamic or /space/67890 on mobile
+		for _, segment := range segments {
+			if digitsPattern.MatchString(segment) {
+				return newRoomRef(RoomRefUID, segment)
+			}
+		}
+	}
+	return BilibiliRoomRef{}, fmt.Errorf("unsupported room reference %q, expected a room ID, live.bilibili.com URL, space.bilibili.com URL or uid:<UID>", ref)
+}
+
+// newRoomRef checks the ID of a reference
+func newRoomRef(kind, id string) (BilibiliRoomRef, error) {
+	if err := validateRoomID(id); err != nil {
+		return BilibiliRoomRef{}, fmt.Errorf("invalid %s %q: %w", kind, id, err)
+	}
+	return BilibiliRoomRef{Kind: kind, ID: id}, nil
+}
+
+// ResolveRoomRefs resolves room references to real room IDs, keyed by
+// reference, so that short and real IDs of the same room always give the
+// same ID. Room IDs are resolved with one batch request and UIDs are looked
+// up one by one. References that cannot be resolved, e.g. because the
+// request failed, are left out and reported in the error.
+func (c *BilibiliClient) ResolveRoomRefs(ctx context.Context, refs []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(refs))
+	parsed := make(map[string]BilibiliRoomRef, len(refs))
+	var roomIDs []string
+	var errs []error
+
+	for _, ref := range refs {
+		roomRef, err := ParseBilibiliRoomRef(ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parsed[ref] = roomRef
+		if roomRef.Kind == RoomRefRoomID {
+			roomIDs = append(roomIDs, roomRef.ID)
+		}
+	}
+
+	poller := NewBilibiliBatchPoller(c)
+	if len(roomIDs) > 0 {
+		if err := poller.Refresh(ctx, roomIDs); err != nil {
+			c.logger.WithError(err).Warn("Failed to resolve room IDs")
+		}
+	}
+
+	for _, ref := range refs {
+		roomRef, ok := parsed[ref]
+		if !ok {
+			continue
+		}
+
+		switch roomRef.Kind {
+		case RoomRefRoomID:
+			status, ok := poller.Lookup(roomRef.ID)
+			if !ok {
+				errs = append(errs, fmt.Errorf("room %s: could not resolve room %s to its real room ID", ref, roomRef.ID))
+				continue
+			}
+			resolved[ref] = status.RoomID
+		case RoomRefUID:
+			roomID, err := c.RoomIDByUID(ctx, roomRef.ID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("room %s: %w", ref, err))
+				continue
+			}
+			resolved[ref] = roomID
+		}
+	}
+
+	return resolved, errors.Join(errs...)
+}
+
+// RoomIDByUID returns the real room ID of a streamer's live room
+func (c *BilibiliClient) RoomIDByUID(ctx context.Context, uid string) (string, error) {
+	body, err := c.getCached(ctx, roomInfoOldURL, map[string]string{
+		"mid": uid,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get room of user %s: %w", uid, err)
+	}
+
+	var data struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			RoomStatus int   `json:"roomStatus"` // 0 if the user has no live room
+			RoomID     int64 `json:"roomid"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if data.Code != 0 {
+		return "", fmt.Errorf("API error (code %d): %s", data.Code, data.Msg)
+	}
+	if data.Data.RoomStatus == 0 || data.Data.RoomID == 0 {
+		return "", fmt.Errorf("user %s has no live room", uid)
+	}
+
+	return strconv.FormatInt(data.Data.RoomID, 10), nil
+}
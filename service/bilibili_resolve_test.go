@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBilibiliRoomRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want BilibiliRoomRef
+	}{
+		{"76", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "76"}},
+		{" 22637261 ", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "22637261"}},
+		{"https://live.bilibili.com/22637261?spm_id_from=333.999&live_from=85001", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "22637261"}},
+		{"live.bilibili.com/76", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "76"}},
+		{"https://live.bilibili.com/h5/76", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "76"}},
+		{"https://live.bilibili.com/blanc/76/", BilibiliRoomRef{Kind: RoomRefRoomID, ID: "76"}},
+		{"https://space.bilibili.com/1001", BilibiliRoomRef{Kind: RoomRefUID, ID: "1001"}},
+		{"https://space.bilibili.com/1001/dynamic?spm_id_from=333", BilibiliRoomRef{Kind: RoomRefUID, ID: "1001"}},
+		{"https://m.bilibili.com/space/1001", BilibiliRoomRef{Kind: RoomRefUID, ID: "1001"}},
+		{"uid:1001", BilibiliRoomRef{Kind: RoomRefUID, ID: "1001"}},
+		{"UID: 1001", BilibiliRoomRef{Kind: RoomRefUID, ID: "1001"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseBilibiliRoomRef(tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.want, got, tt.ref)
+	}
+
+	for _, ref := range []string{
+		"",
+		"abc",
+		"uid:abc",
+		"https://live.bilibili.com/",
+		"https://live.bilibili.com/p/eden/area-tags",
+		"https://www.bilibili.com/video/BV1xx411c7mD",
+		"https://example.com/76",
+	} {
+		_, err := ParseBilibiliRoomRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+// newResolveStandIn serves getRoomBaseInfo, where room 76 is the short ID
+// of 22637261, and getRoomInfoOld, where user 1001 owns room 22637261 and
+// user 2002 has no room
+func newResolveStandIn(t *testing.T, batches *atomic.Int32) *BilibiliClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + roomBaseInfoURL:
+			batches.Add(1)
+			fmt.Fprint(w, `{"code":0,"msg":"ok","data":{"by_room_ids":{"22637261":{"room_id":22637261,"short_id":76,"live_status":0}}}}`)
+		case "/" + roomInfoOldURL:
+			if r.URL.Query().Get("mid") == "1001" {
+				fmt.Fprint(w, `{"code":0,"message":"0","data":{"roomStatus":1,"roomid":22637261}}`)
+				return
+			}
+			fmt.Fprint(w, `{"code":0,"message":"0","data":{"roomStatus":0,"roomid":0}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	primeWbiKeys(client)
+	return client
+}
+
+func TestBilibiliClient_ResolveRoomRefs(t *testing.T) {
+	var batches atomic.Int32
+	client := newResolveStandIn(t, &batches)
+
+	refs := []string{
+		"76",
+		"https://live.bilibili.com/22637261?spm_id_from=333",
+		"https://space.bilibili.com/1001",
+		"uid:2002",
+		"300",
+	}
+	resolved, err := client.ResolveRoomRefs(context.Background(), refs)
+	assert.ErrorContains(t, err, "room uid:2002: user 2002 has no live room")
+	assert.ErrorContains(t, err, "room 300: could not resolve room 300 to its real room ID")
+
+	// Short IDs, URLs and UIDs of the same room resolve to one real room ID
+	assert.Equal(t, map[string]string{
+		"76": "22637261",
+		"https://live.bilibili.com/22637261?spm_id_from=333": "22637261",
+		"https://space.bilibili.com/1001":                    "22637261",
+	}, resolved)
+	assert.Equal(t, int32(1), batches.Load())
+}
+
+func TestBilibiliClient_ResolveSingleRoomRef(t *testing.T) {
+	var batches atomic.Int32
+	client := newResolveStandIn(t, &batches)
+
+	// A lone short ID resolves to the same real room ID as in a batch
+	resolved, err := client.ResolveRoomRefs(context.Background(), []string{"live.bilibili.com/76"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"live.bilibili.com/76": "22637261"}, resolved)
+	assert.Equal(t, int32(1), batches.Load())
+
+	roomID, err := client.RoomIDByUID(context.Background(), "1001")
+	require.NoError(t, err)
+	assert.Equal(t, "22637261", roomID)
+}
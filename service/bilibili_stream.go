@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Bilibili quality numbers (qn)
+const (
+	qnDolby    = 30000
+	qn4K       = 20000
+	qnOriginal = 10000
+	qnBluRay   = 400
+	qnUltra    = 250
+	qnHigh     = 150
+	qnSmooth   = 80
+)
+
+// bilibiliQualities maps relay quality names to quality numbers
+var bilibiliQualities = map[string]int{
+	"dolby":    qnDolby,
+	"4k":       qn4K,
+	"original": qnOriginal,
+	"bluray":   qnBluRay,
+	"1080p":    qnBluRay,
+	"ultra":    qnUltra,
+	"720p":     qnUltra,
+	"high":     qnHigh,
+	"480p":     qnHigh,
+	"smooth":   qnSmooth,
+	"360p":     qnSmooth,
+}
+
+// bilibiliQuality resolves a relay quality to the qn to request and the
+// preference used to rank the returned streams. Besides the names in
+// bilibiliQualities, "best", "worst" and numeric qn values are accepted.
+func bilibiliQuality(quality, codec string) (int, models.StreamPreference, error) {
+	pref := models.StreamPreference{Codec: codec}
+
+	switch quality = strings.ToLower(quality); quality {
+	case "", "best":
+		return qnOriginal, pref, nil
+	case "worst":
+		pref.Qn = 1
+		return qnSmooth, pref, nil
+	}
+
+	qn, ok := bilibiliQualities[quality]
+	if !ok {
+		var err error
+		if qn, err = strconv.Atoi(quality); err != nil || qn <= 0 {
+			return 0, pref, fmt.Errorf("unsupported quality %q", quality)
+		}
+	}
+	pref.Qn = qn
+	return qn, pref, nil
+}
+
+// ValidateBilibiliStream checks a relay's quality and codec settings
+func ValidateBilibiliStream(quality, codec string) error {
+	if _, _, err := bilibiliQuality(quality, codec); err != nil {
+		return err
+	}
+	switch codec {
+	case "", models.CodecAVC, models.CodecHEVC:
+		return nil
+	}
+	return fmt.Errorf("unsupported codec %q", codec)
+}
+
+// GetStreamCandidates returns the room's streams ranked by the quality and
+// codec preference
+func (b *BilibiliService) GetStreamCandidates(realRoomId, quality, codec string) ([]models.StreamCandidate, error) {
+	return b.GetStreamCandidatesContext(context.Background(), realRoomId, quality, codec)
+}
+
+// GetStreamCandidatesContext is like GetStreamCandidates with a context that cancels the request and its retries
+func (b *BilibiliService) GetStreamCandidatesContext(ctx context.Context, realRoomId, quality, codec string) ([]models.StreamCandidate, error) {
+	if err := validateRoomID(realRoomId); err != nil {
+		return nil, fmt.Errorf("invalid real room ID: %w", err)
+	}
+	qn, pref, err := bilibiliQuality(quality, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := b.roomPlayInfoStreams(ctx, realRoomId, qn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rooms without getRoomPlayInfo streams may still be served by the older API
+	if len(candidates) == 0 {
+		candidates, err = b.playURLStreams(ctx, realRoomId, qn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no live stream URLs found for room %s", realRoomId)
+	}
+	return models.RankStreams(candidates, pref), nil
+}
+
+// roomPlayInfoStreams reads every protocol, format and codec variant from
+// getRoomPlayInfo's playurl_info.playurl.stream
+func (b *BilibiliService) roomPlayInfoStreams(ctx context.Context, realRoomId string, qn int) ([]models.StreamCandidate, error) {
+	resp, err := b.Client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"room_id":    realRoomId,
+			"no_playurl": "0",
+			"mask":       "0",
+			"qn":         strconv.Itoa(qn),
+			"platform":   "web",
+			"protocol":   "0,1",
+			"format":     "0,1,2",
+			"codec":      "0,1",
+		}).
+		Get(roomPlayInfoURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room play info: %w", err)
+	}
+
+	var data struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			PlayUrlInfo struct {
+				PlayUrl struct {
+					Stream []struct {
+						ProtocolName string `json:"protocol_name"`
+						Format       []struct {
+							FormatName string `json:"format_name"`
+							Codec      []struct {
+								CodecName string `json:"codec_name"`
+								CurrentQn int    `json:"current_qn"`
+								BaseURL   string `json:"base_url"`
+								URLInfo   []struct {
+									Host      string `json:"host"`
+									Extra     string `json:"extra"`
+									StreamTTL int    `json:"stream_ttl"`
+								} `json:"url_info"`
+							} `json:"codec"`
+						} `json:"format"`
+					} `json:"stream"`
+				} `json:"playurl"`
+			} `json:"playurl_info"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		b.logger.WithFields(logrus.Fields{
+			"error":           err,
+			"response_length": len(resp.Body()),
+		}).Error("Failed to parse room play info response")
+		return nil, fmt.Errorf("failed to parse room play info response: %w", err)
+	}
+
+	if data.Code != 0 {
+		return nil, fmt.Errorf("room play info API error (code %d): %s", data.Code, data.Msg)
+	}
+
+	now := time.Now()
+	var candidates []models.StreamCandidate
+	for _, stream := range data.Data.PlayUrlInfo.PlayUrl.Stream {
+		for _, format := range stream.Format {
+			for _, codec := range format.Codec {
+				for _, info := range codec.URLInfo {
+					candidate := models.StreamCandidate{
+						URL:      info.Host + codec.BaseURL + info.Extra,
+						Protocol: stream.ProtocolName,
+						Format:   format.FormatName,
+						Codec:    codec.CodecName,
+						Qn:       codec.CurrentQn,
+						Host:     streamHost(info.Host),
+						Expiry:   streamExpiry(info.Extra),
+					}
+					if candidate.Expiry.IsZero() && info.StreamTTL > 0 {
+						candidate.Expiry = now.Add(time.Duration(info.StreamTTL) * time.Second)
+					}
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// playURLStreams reads the FLV streams of the older playUrl API
+func (b *BilibiliService) playURLStreams(ctx context.Context, realRoomId string, qn int) ([]models.StreamCandidate, error) {
+	resp, err := b.Client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"cid":      realRoomId,
+			"qn":       strconv.Itoa(qn),
+			"platform": "web",
+		}).
+		Get(playURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get play URL: %w", err)
+	}
+
+	var data struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			CurrentQn int `json:"current_qn"`
+			Durl      []struct {
+				URL string `json:"url"`
+			} `json:"durl"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse play URL response: %w", err)
+	}
+
+	if data.Code != 0 {
+		return nil, fmt.Errorf("play URL API error (code %d): %s", data.Code, data.Msg)
+	}
+
+	candidates := make([]models.StreamCandidate, 0, len(data.Data.Durl))
+	for _, durl := range data.Data.Durl {
+		u, err := url.Parse(durl.URL)
+		if err != nil {
+			b.logger.WithError(err).WithField("url", durl.URL).Warn("Failed to parse URL")
+			continue
+		}
+		candidates = append(candidates, models.StreamCandidate{
+			URL:      durl.URL,
+			Protocol: models.ProtocolHTTPStream,
+			Format:   models.FormatFLV,
+			Codec:    models.CodecAVC,
+			Qn:       data.Data.CurrentQn,
+			Host:     u.Host,
+			Expiry:   streamExpiry(u.RawQuery),
+		})
+	}
+
+	return candidates, nil
+}
+
+// streamHost returns the host name of a url_info host such as
+// https://cn-gddg-ct-01-01.bilivideo.com
+func streamHost(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return host
+}
+
+// streamExpiry reads the expires parameter of a stream URL query
+func streamExpiry(query string) time.Time {
+	values, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		return time.Time{}
+	}
+	expires, err := strconv.ParseInt(values.Get("expires"), 10, 64)
+	if err != nil || expires <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(expires, 0)
+}
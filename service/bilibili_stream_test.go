@@ -0,0 +1,130 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nick3/restreamer_monitor_go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roomPlayInfoResponse has an FLV and an HLS stream for AVC and HEVC at qn 10000
+const roomPlayInfoResponse = `{"code":0,"msg":"0","data":{"room_id":22637261,"live_status":1,"playurl_info":{"playurl":{"stream":[
+{"protocol_name":"http_stream","format":[{"format_name":"flv","codec":[
+	{"codec_name":"avc","current_qn":10000,"accept_qn":[10000,400,250],"base_url":"/live-bvc/123/live_1_2.flv?","url_info":[
+		{"host":"https://cn-gddg-ct-01-01.bilivideo.com","extra":"expires=1700003600&len=0","stream_ttl":3600},
+		{"host":"https://d1--cn-gotcha03.bilivideo.com","extra":"expires=1700003600&len=0","stream_ttl":3600}]}]}]},
+{"protocol_name":"http_hls","format":[
+	{"format_name":"ts","codec":[{"codec_name":"avc","current_qn":10000,"accept_qn":[10000,400,250],"base_url":"/live-bvc/123/live_1_2/index.m3u8?","url_info":[
+		{"host":"https://cn-gddg-ct-01-01.bilivideo.com","extra":"expires=1700003600","stream_ttl":3600}]}]},
+	{"format_name":"fmp4","codec":[{"codec_name":"hevc","current_qn":10000,"accept_qn":[10000,400,250],"base_url":"/live-bvc/123/live_1_2_h265/index.m3u8?","url_info":[
+		{"host":"https://cn-gddg-ct-01-01.bilivideo.com","extra":"","stream_ttl":3600}]}]}]}
+]}}}}`
+
+func newPlayInfoStandIn(t *testing.T, playInfo, playURLResponse string) *BilibiliClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + roomPlayInfoURL:
+			assert.Equal(t, "22637261", r.URL.Query().Get("room_id"))
+			_, _ = w.Write([]byte(playInfo))
+		case "/" + playURL:
+			_, _ = w.Write([]byte(playURLResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	primeWbiKeys(client)
+	return client
+}
+
+func TestBilibiliService_GetStreamCandidates(t *testing.T) {
+	client := newPlayInfoStandIn(t, roomPlayInfoResponse, "")
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	candidates, err := svc.GetStreamCandidates("22637261", "best", "")
+	require.NoError(t, err)
+	require.Len(t, candidates, 4)
+
+	// AVC over FLV comes first, one candidate per CDN host
+	assert.Equal(t, models.StreamCandidate{
+		URL:      "https://cn-gddg-ct-01-01.bilivideo.com/live-bvc/123/live_1_2.flv?expires=1700003600&len=0",
+		Protocol: models.ProtocolHTTPStream,
+		Format:   models.FormatFLV,
+		Codec:    models.CodecAVC,
+		Qn:       10000,
+		Host:     "cn-gddg-ct-01-01.bilivideo.com",
+		Expiry:   time.Unix(1700003600, 0),
+	}, candidates[0])
+	assert.Equal(t, "d1--cn-gotcha03.bilivideo.com", candidates[1].Host)
+	assert.Equal(t, models.FormatTS, candidates[2].Format)
+
+	// Without an expires parameter the stream TTL is used
+	hevc := candidates[3]
+	assert.Equal(t, models.CodecHEVC, hevc.Codec)
+	assert.Equal(t, models.FormatFMP4, hevc.Format)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), hevc.Expiry, time.Minute)
+
+	urls, err := svc.GetBilibiliLiveRealURL("22637261")
+	require.NoError(t, err)
+	assert.Equal(t, candidates[0].URL, urls[0])
+
+	candidates, err = svc.GetStreamCandidates("22637261", "best", "hevc")
+	require.NoError(t, err)
+	assert.Equal(t, models.CodecHEVC, candidates[0].Codec)
+}
+
+func TestBilibiliService_GetStreamCandidatesFallback(t *testing.T) {
+	client := newPlayInfoStandIn(t,
+		`{"code":0,"data":{"playurl_info":{"playurl":{"stream":[]}}}}`,
+		`{"code":0,"data":{"current_qn":250,"durl":[{"url":"https://d1--cn-gotcha04.bilivideo.com/live-bvc/1/live.flv?expires=1700003600"}]}}`)
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	candidates, err := svc.GetStreamCandidates("22637261", "720p", "")
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "d1--cn-gotcha04.bilivideo.com", candidates[0].Host)
+	assert.Equal(t, 250, candidates[0].Qn)
+	assert.Equal(t, models.FormatFLV, candidates[0].Format)
+	assert.Equal(t, time.Unix(1700003600, 0), candidates[0].Expiry)
+
+	_, err = svc.GetStreamCandidates("22637261", "8k", "")
+	assert.ErrorContains(t, err, `unsupported quality "8k"`)
+}
+
+func TestBilibiliQuality(t *testing.T) {
+	tests := []struct {
+		quality   string
+		requestQn int
+		rankQn    int
+	}{
+		{"", 10000, 0},
+		{"best", 10000, 0},
+		{"worst", 80, 1},
+		{"720p", 250, 250},
+		{"1080P", 400, 400},
+		{"original", 10000, 10000},
+		{"150", 150, 150},
+	}
+	for _, tt := range tests {
+		qn, pref, err := bilibiliQuality(tt.quality, "avc")
+		require.NoError(t, err, tt.quality)
+		assert.Equal(t, tt.requestQn, qn, tt.quality)
+		assert.Equal(t, tt.rankQn, pref.Qn, tt.quality)
+		assert.Equal(t, "avc", pref.Codec)
+	}
+
+	assert.NoError(t, ValidateBilibiliStream("720p", "hevc"))
+	assert.Error(t, ValidateBilibiliStream("8k", ""))
+	assert.Error(t, ValidateBilibiliStream("best", "av1"))
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -142,3 +145,57 @@ func TestGetRoomInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestBilibiliService_ContextCancellation(t *testing.T) {
+	// The server never answers, so only the context can end the calls
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL)
+	primeWbiKeys(client)
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	calls := map[string]func(ctx context.Context) error{
+		"GetBilibiliRealRoomIdContext": func(ctx context.Context) error {
+			_, err := svc.GetBilibiliRealRoomIdContext(ctx)
+			return err
+		},
+		"GetBilibiliLiveStatusContext": func(ctx context.Context) error {
+			_, err := svc.GetBilibiliLiveStatusContext(ctx)
+			return err
+		},
+		"GetRoomBaseInfoContext": func(ctx context.Context) error {
+			_, err := svc.GetRoomBaseInfoContext(ctx)
+			return err
+		},
+		"GetRoomInfoContext": func(ctx context.Context) error {
+			_, err := svc.GetRoomInfoContext(ctx)
+			return err
+		},
+		"GetBilibiliLiveRealURLContext": func(ctx context.Context) error {
+			_, err := svc.GetBilibiliLiveRealURLContext(ctx, "76")
+			return err
+		},
+		"GetDanmuInfoContext": func(ctx context.Context) error {
+			_, err := svc.GetDanmuInfoContext(ctx, "76")
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			// Retries are abandoned as well, long before the request timeout
+			start := time.Now()
+			err := call(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), 2*time.Second)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	navURL = "https://api.bilibili.com/x/web-interface/nav"
+	// wbiKeyRefreshInterval is how long fetched WBI keys are used; Bilibili rotates them daily
+	wbiKeyRefreshInterval = 24 * time.Hour
+)
+
+// wbiEndpoints are the web API endpoints that reject unsigned requests
+var wbiEndpoints = map[string]bool{
+	roomBaseInfoURL: true,
+	roomPlayInfoURL: true,
+	danmuInfoURL:    true,
+}
+
+// mixinKeyEncTab is the permutation that derives the mixin key from img_key and sub_key
+var mixinKeyEncTab = []int{
+	46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
+	33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
+	61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
+	36, 20, 34, 44, 52,
+}
+
+// WbiSigner adds WBI signatures (wts and w_rid) to web API requests. The
+// img_key and sub_key come from the nav endpoint and are refreshed daily.
+type WbiSigner struct {
+	Client          *resty.Client
+	NavURL          string
+	RefreshInterval time.Duration
+	logger          *logrus.Entry
+
+	mu        sync.Mutex
+	mixinKey  string
+	fetchedAt time.Time
+}
+
+// NewWbiSigner creates a signer that fetches its keys through client
+func NewWbiSigner(client *resty.Client, logger *logrus.Entry) *WbiSigner {
+	return &WbiSigner{
+		Client:          client,
+		NavURL:          navURL,
+		RefreshInterval: wbiKeyRefreshInterval,
+		logger:          logger,
+	}
+}
+
+// Sign replaces the wts and w_rid parameters of params with a fresh signature
+func (s *WbiSigner) Sign(ctx context.Context, params url.Values) error {
+	mixinKey, err := s.key(ctx)
+	if err != nil {
+		return err
+	}
+	signWbi(params, mixinKey, time.Now().Unix())
+	return nil
+}
+
+// key returns the current mixin key, fetching new keys when they are older
+// than RefreshInterval. Stale keys are kept if the refresh fails.
+func (s *WbiSigner) key(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mixinKey != "" && time.Since(s.fetchedAt) < s.RefreshInterval {
+		return s.mixinKey, nil
+	}
+
+	imgKey, subKey, err := s.fetchKeys(ctx)
+	if err != nil {
+		if s.mixinKey != "" {
+			s.logger.WithError(err).Warn("Failed to refresh WBI keys, using previous keys")
+			return s.mixinKey, nil
+		}
+		return "", fmt.Errorf("failed to get WBI keys: %w", err)
+	}
+
+	s.setKeys(imgKey, subKey)
+	return s.mixinKey, nil
+}
+
+// setKeys stores new keys. Must be called with s.mu held.
+func (s *WbiSigner) setKeys(imgKey, subKey string) {
+	s.mixinKey = getMixinKey(imgKey, subKey)
+	s.fetchedAt = time.Now()
+}
+
+// Invalidate discards the cached keys so the next request fetches new ones
+func (s *WbiSigner) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mixinKey = ""
+}
+
+// fetchKeys reads img_key and sub_key from the nav endpoint. Logged-out
+// clients get code -101 there, but the keys are still included.
+func (s *WbiSigner) fetchKeys(ctx context.Context) (string, string, error) {
+	resp, err := s.Client.R().
+		SetContext(ctx).
+		Get(s.NavURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	var data struct {
+		Code int `json:"code"`
+		Data struct {
+			WbiImg struct {
+				ImgURL string `json:"img_url"`
+				SubURL string `json:"sub_url"`
+			} `json:"wbi_img"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return "", "", fmt.Errorf("failed to parse nav response: %w", err)
+	}
+
+	imgKey := wbiKeyFromURL(data.Data.WbiImg.ImgURL)
+	subKey := wbiKeyFromURL(data.Data.WbiImg.SubURL)
+	if imgKey == "" || subKey == "" {
+		return "", "", fmt.Errorf("nav response has no WBI keys (code %d)", data.Code)
+	}
+	return imgKey, subKey, nil
+}
+
+// wbiKeyFromURL extracts a key from an image URL such as
+// https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png
+func wbiKeyFromURL(imageURL string) string {
+	if imageURL == "" {
+		return ""
+	}
+	name := path.Base(imageURL)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// signRequest signs requests to endpoints in wbiEndpoints. It runs before
+// every request, including retries, so each attempt carries a fresh wts.
+func (c *BilibiliClient) signRequest(_ *resty.Client, req *resty.Request) error {
+	if !wbiEndpoints[strings.TrimPrefix(req.URL, "/")] {
+		return nil
+	}
+	if err := c.Wbi.Sign(req.Context(), req.QueryParam); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+	return nil
+}
+
+// invalidateRejectedKeys drops the WBI keys when a signed request is
+// answered with -352, which Bilibili also returns for outdated keys
+func (c *BilibiliClient) invalidateRejectedKeys(resp *resty.Response, code int) {
+	if code != -352 || resp.Request.QueryParam.Get("w_rid") == "" {
+		return
+	}
+	c.logger.Info("Signed request rejected, fetching new WBI keys on the next request")
+	c.Wbi.Invalidate()
+}
+
+// getMixinKey shuffles img_key and sub_key into the 32-character mixin key
+func getMixinKey(imgKey, subKey string) string {
+	raw := imgKey + subKey
+	var key strings.Builder
+	for _, index := range mixinKeyEncTab {
+		if index < len(raw) {
+			key.WriteByte(raw[index])
+		}
+	}
+	mixinKey := key.String()
+	if len(mixinKey) > 32 {
+		mixinKey = mixinKey[:32]
+	}
+	return mixinKey
+}
+
+// signWbi sets wts and w_rid on params. w_rid is the MD5 of the sorted,
+// filtered query string followed by the mixin key.
+func signWbi(params url.Values, mixinKey string, wts int64) {
+	params.Del("w_rid")
+	params.Set("wts", strconv.FormatInt(wts, 10))
+
+	// The server drops these characters from values before verifying
+	for _, values := range params {
+		for i, value := range values {
+			values[i] = strings.Map(func(r rune) rune {
+				if strings.ContainsRune("!'()*", r) {
+					return -1
+				}
+				return r
+			}, value)
+		}
+	}
+
+	query := strings.ReplaceAll(params.Encode(), "+", "%20")
+	hash := md5.Sum([]byte(query + mixinKey))
+	params.Set("w_rid", hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testImgKey = "7cd084941338484aae1ad9425b84077c"
+	testSubKey = "4932caff0ff746eab6f01bf08b70ac45"
+)
+
+// primeWbiKeys gives a test client WBI keys without a nav request
+func primeWbiKeys(client *BilibiliClient) {
+	client.Wbi.mu.Lock()
+	defer client.Wbi.mu.Unlock()
+	client.Wbi.setKeys(testImgKey, testSubKey)
+}
+
+func TestGetMixinKey(t *testing.T) {
+	assert.Equal(t, "ea1db124af3c7062474693fa704f4ff8", getMixinKey(testImgKey, testSubKey))
+}
+
+func TestSignWbi(t *testing.T) {
+	params := url.Values{}
+	params.Set("foo", "114")
+	params.Set("bar", "514")
+	params.Set("zab", "1919810")
+
+	signWbi(params, getMixinKey(testImgKey, testSubKey), 1702204169)
+	assert.Equal(t, "1702204169", params.Get("wts"))
+	assert.Equal(t, "8f6f2b5b3d485fe1886cec6a0be8c5d4", params.Get("w_rid"))
+
+	// Signing again replaces the previous signature
+	signWbi(params, getMixinKey(testImgKey, testSubKey), 1702204169)
+	assert.Equal(t, []string{"8f6f2b5b3d485fe1886cec6a0be8c5d4"}, params["w_rid"])
+
+	// Characters the server drops are removed from the signed values
+	params = url.Values{"title": {"(live)!"}}
+	signWbi(params, "key", 1)
+	assert.Equal(t, "live", params.Get("title"))
+}
+
+func TestWbiKeyFromURL(t *testing.T) {
+	assert.Equal(t, testImgKey, wbiKeyFromURL("https://i0.hdslb.com/bfs/wbi/"+testImgKey+".png"))
+	assert.Equal(t, "", wbiKeyFromURL(""))
+}
+
+// newNavStandIn serves the nav endpoint as a logged-out client sees it
+func newNavStandIn(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"isLogin":false,"wbi_img":{"img_url":"https://i0.hdslb.com/bfs/wbi/` + testImgKey + `.png","sub_url":"https://i0.hdslb.com/bfs/wbi/` + testSubKey + `.png"}}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWbiSigner_KeyRefresh(t *testing.T) {
+	var requests atomic.Int32
+	server := newNavStandIn(t, &requests)
+
+	client := NewBilibiliClient()
+	client.HTTP.SetRetryCount(0)
+	signer := client.Wbi
+	signer.NavURL = server.URL
+
+	params := url.Values{"room_id": {"76"}}
+	require.NoError(t, signer.Sign(context.Background(), params))
+	require.NoError(t, signer.Sign(context.Background(), params))
+	assert.Equal(t, int32(1), requests.Load(), "keys are cached between requests")
+	assert.NotEmpty(t, params.Get("w_rid"))
+
+	// Keys older than the refresh interval are fetched again
+	signer.RefreshInterval = 50 * time.Millisecond
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, signer.Sign(context.Background(), params))
+	assert.Equal(t, int32(2), requests.Load())
+
+	// A failed refresh keeps the previous keys
+	server.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, signer.Sign(context.Background(), params))
+
+	// Without any keys the request cannot be signed
+	signer.Invalidate()
+	assert.ErrorContains(t, signer.Sign(context.Background(), params), "failed to get WBI keys")
+}
+
+func TestBilibiliClient_SignsWebEndpoints(t *testing.T) {
+	var navRequests atomic.Int32
+	nav := newNavStandIn(t, &navRequests)
+
+	queries := make(chan url.Values, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query()
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"room_id":22637261,"short_id":76,"live_status":1,"by_room_ids":{}}}`))
+	}))
+	defer server.Close()
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.Wbi.NavURL = nav.URL
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	// getRoomBaseInfo needs a signature
+	_, _ = svc.GetRoomBaseInfo()
+	query := <-queries
+	assert.Equal(t, "76", query.Get("room_ids"))
+	assert.NotEmpty(t, query.Get("wts"))
+	assert.Len(t, query.Get("w_rid"), 32)
+
+	// room_init does not
+	_, err = svc.GetBilibiliLiveStatus()
+	require.NoError(t, err)
+	query = <-queries
+	assert.Empty(t, query.Get("w_rid"))
+
+	assert.Equal(t, int32(1), navRequests.Load())
+}
+
+func TestBilibiliClient_RejectedSignatureInvalidatesKeys(t *testing.T) {
+	var navRequests atomic.Int32
+	nav := newNavStandIn(t, &navRequests)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":-352,"message":"-352","data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewBilibiliClient()
+	client.HTTP.SetBaseURL(server.URL).SetRetryCount(0)
+	client.Wbi.NavURL = nav.URL
+
+	svc, err := NewBilibiliServiceWithClient("76", client)
+	require.NoError(t, err)
+
+	_, err = svc.GetRoomBaseInfo()
+	assert.ErrorIs(t, err, ErrRiskControl)
+	assert.Equal(t, int32(1), navRequests.Load())
+
+	client.Wbi.mu.Lock()
+	defer client.Wbi.mu.Unlock()
+	assert.Empty(t, client.Wbi.mixinKey, "the rejected keys are fetched again")
+}
@@ -0,0 +1,708 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// Bilibili live message protocol constants
+const (
+	danmuInfoURL = "xlive/web-room/v1/index/getDanmuInfo"
+
+	danmakuDefaultURL        = "wss://broadcastlv.chat.bilibili.com/sub"
+	danmakuHeaderLength      = 16
+	danmakuHeartbeatInterval = 30 * time.Second
+	danmakuAuthTimeout       = 10 * time.Second
+	danmakuMinBackoff        = time.Second
+	danmakuMaxBackoff        = time.Minute
+	danmakuEventBuffer       = 256
+
+	// Protocol versions carried in the packet header
+	danmakuProtoJSON      = 0
+	danmakuProtoHeartbeat = 1
+	danmakuProtoZlib      = 2
+	danmakuProtoBrotli    = 3
+
+	// Operations carried in the packet header
+	danmakuOpHeartbeat      = 2
+	danmakuOpHeartbeatReply = 3
+	danmakuOpMessage        = 5
+	danmakuOpAuth           = 7
+	danmakuOpAuthReply      = 8
+)
+
+// DanmakuEventType identifies the kind of a decoded live room message
+type DanmakuEventType string
+
+// Supported live room message types, named after their protocol cmd
+const (
+	DanmakuEventDanmaku    DanmakuEventType = "DANMU_MSG"
+	DanmakuEventGift       DanmakuEventType = "SEND_GIFT"
+	DanmakuEventSuperChat  DanmakuEventType = "SUPER_CHAT_MESSAGE"
+	DanmakuEventLive       DanmakuEventType = "LIVE"
+	DanmakuEventPreparing  DanmakuEventType = "PREPARING"
+	DanmakuEventRoomChange DanmakuEventType = "ROOM_CHANGE"
+)
+
+// isStatus reports whether the event changes the status or info of the room
+func (t DanmakuEventType) isStatus() bool {
+	return t == DanmakuEventLive || t == DanmakuEventPreparing || t == DanmakuEventRoomChange
+}
+
+// DanmakuEvent represents a decoded message received from a live room.
+// Exactly one of the payload fields is set depending on Type; LIVE and
+// PREPARING events carry no payload.
+type DanmakuEvent struct {
+	Type       DanmakuEventType
+	RoomID     string
+	Timestamp  time.Time
+	Rotation   bool // Set on PREPARING when the room switches to rotation playback
+	Danmaku    *DanmakuMessage
+	Gift       *GiftMessage
+	SuperChat  *SuperChatMessage
+	RoomChange *RoomChangeMessage
+	Raw        json.RawMessage
+}
+
+// DanmakuMessage is a chat message sent by a viewer
+type DanmakuMessage struct {
+	UID     int64
+	UName   string
+	Content string
+}
+
+// GiftMessage is a gift sent by a viewer
+type GiftMessage struct {
+	UID      int64
+	UName    string
+	GiftName string
+	Num      int
+	Price    int
+	CoinType string
+}
+
+// SuperChatMessage is a paid message pinned in the room
+type SuperChatMessage struct {
+	UID     int64
+	UName   string
+	Message string
+	Price   int
+}
+
+// RoomChangeMessage is sent when the anchor changes the room title or area
+type RoomChangeMessage struct {
+	Title          string
+	AreaName       string
+	ParentAreaName string
+}
+
+// DanmuInfo holds the credentials needed to connect to the message servers
+type DanmuInfo struct {
+	Token string
+	URLs  []string
+}
+
+// DanmakuConnectFunc resolves the real room ID and connection info for a client
+type DanmakuConnectFunc func(ctx context.Context) (roomID int, info *DanmuInfo, err error)
+
+// danmakuPacket is a single decoded protocol packet
+type danmakuPacket struct {
+	Version   uint16
+	Operation uint32
+	Body      []byte
+}
+
+// GetDanmuInfo retrieves the message server token and host list for a room
+func (b *BilibiliService) GetDanmuInfo(realRoomId string) (*DanmuInfo, error) {
+	return b.GetDanmuInfoContext(context.Background(), realRoomId)
+}
+
+// GetDanmuInfoContext is like GetDanmuInfo with a context that cancels the request and its retries
+func (b *BilibiliService) GetDanmuInfoContext(ctx context.Context, realRoomId string) (*DanmuInfo, error) {
+	if err := validateRoomID(realRoomId); err != nil {
+		return nil, fmt.Errorf("invalid real room ID: %w", err)
+	}
+
+	resp, err := b.Client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"id":   realRoomId,
+			"type": "0",
+		}).
+		Get(danmuInfoURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get danmu info: %w", err)
+	}
+
+	var data struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Token    string `json:"token"`
+			HostList []struct {
+				Host    string `json:"host"`
+				WssPort int    `json:"wss_port"`
+			} `json:"host_list"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if data.Code != 0 {
+		return nil, fmt.Errorf("API error (code %d): %s", data.Code, data.Message)
+	}
+
+	info := &DanmuInfo{Token: data.Data.Token}
+	for _, host := range data.Data.HostList {
+		if host.Host == "" {
+			continue
+		}
+		port := host.WssPort
+		if port == 0 {
+			port = 443
+		}
+		info.URLs = append(info.URLs, fmt.Sprintf("wss://%s:%d/sub", host.Host, port))
+	}
+
+	return info, nil
+}
+
+// NewDanmakuClient creates a message client for the service's room.
+// The real room ID and server list are resolved on every (re)connect.
+func (b *BilibiliService) NewDanmakuClient() *DanmakuClient {
+	client := NewDanmakuClient(func(ctx context.Context) (int, *DanmuInfo, error) {
+		realRoomId, err := b.GetBilibiliRealRoomIdContext(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		roomID, err := strconv.Atoi(realRoomId)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid real room ID %q: %w", realRoomId, err)
+		}
+
+		info, err := b.GetDanmuInfoContext(ctx, realRoomId)
+		if err != nil {
+			// The message servers accept anonymous connections without a token
+			b.logger.WithError(err).Warn("Failed to get danmu info, connecting without token")
+			info = &DanmuInfo{}
+		}
+
+		return roomID, info, nil
+	})
+	client.logger = b.logger.WithField("module", "danmaku")
+	return client
+}
+
+// DanmakuClient maintains a WebSocket connection to the Bilibili live message
+// servers and publishes decoded messages on a typed event channel
+type DanmakuClient struct {
+	connect           DanmakuConnectFunc
+	events            chan DanmakuEvent
+	heartbeatInterval time.Duration
+	minBackoff        time.Duration
+	maxBackoff        time.Duration
+	ctx               context.Context
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+	mu                sync.Mutex
+	writeMu           sync.Mutex
+	started           bool
+	closed            bool
+	connected         atomic.Bool
+	popularity        atomic.Int64
+	logger            *logrus.Entry
+}
+
+// NewDanmakuClient creates a new message client using the given connect function
+func NewDanmakuClient(connect DanmakuConnectFunc) *DanmakuClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DanmakuClient{
+		connect:           connect,
+		events:            make(chan DanmakuEvent, danmakuEventBuffer),
+		heartbeatInterval: danmakuHeartbeatInterval,
+		minBackoff:        danmakuMinBackoff,
+		maxBackoff:        danmakuMaxBackoff,
+		ctx:               ctx,
+		cancel:            cancel,
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "service",
+			"platform":  "bilibili",
+			"module":    "danmaku",
+		}),
+	}
+}
+
+// Events returns the channel decoded messages are published on.
+// The channel is closed once the client is closed.
+func (c *DanmakuClient) Events() <-chan DanmakuEvent {
+	return c.events
+}
+
+// Connected reports whether the client currently holds an authenticated connection
+func (c *DanmakuClient) Connected() bool {
+	return c.connected.Load()
+}
+
+// Popularity returns the last popularity value reported by heartbeat replies
+func (c *DanmakuClient) Popularity() int64 {
+	return c.popularity.Load()
+}
+
+// Start connects in the background and keeps reconnecting until Close is called
+func (c *DanmakuClient) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started || c.closed {
+		return
+	}
+	c.started = true
+
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Close stops the client, closes the connection and the event channel
+func (c *DanmakuClient) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	started := c.started
+	c.mu.Unlock()
+
+	c.cancel()
+	c.wg.Wait()
+
+	if !started {
+		close(c.events)
+	}
+}
+
+// run is the reconnect loop
+func (c *DanmakuClient) run() {
+	defer c.wg.Done()
+	defer close(c.events)
+
+	backoff := c.minBackoff
+	for {
+		authenticated, err := c.session()
+		c.connected.Store(false)
+
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		if authenticated {
+			backoff = c.minBackoff
+		}
+
+		c.logger.WithError(err).WithField("backoff", backoff).Warn("Danmaku connection lost, reconnecting")
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// session runs a single connection until it fails or the client is closed.
+// It reports whether authentication succeeded so the caller can reset its backoff.
+func (c *DanmakuClient) session() (bool, error) {
+	roomID, info, err := c.connect(c.ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve connection info: %w", err)
+	}
+	if info == nil {
+		info = &DanmuInfo{}
+	}
+
+	urls := info.URLs
+	if len(urls) == 0 {
+		urls = []string{danmakuDefaultURL}
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", userAgent)
+
+	var conn *websocket.Conn
+	for _, u := range urls {
+		conn, _, err = websocket.DefaultDialer.DialContext(c.ctx, u, header)
+		if err == nil {
+			break
+		}
+		c.logger.WithError(err).WithField("url", u).Debug("Failed to dial danmaku server")
+	}
+	if conn == nil {
+		return false, fmt.Errorf("failed to connect to any danmaku server: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock reads when the client is closed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := c.authenticate(conn, roomID, info.Token); err != nil {
+		return false, err
+	}
+
+	c.connected.Store(true)
+	c.logger.WithField("real_room_id", roomID).Info("Danmaku connection established")
+
+	go c.heartbeat(conn, done)
+
+	room := strconv.Itoa(roomID)
+	for {
+		conn.SetReadDeadline(time.Now().Add(2*c.heartbeatInterval + danmakuAuthTimeout))
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, fmt.Errorf("failed to read message: %w", err)
+		}
+
+		packets, err := decodeDanmakuPackets(data)
+		if err != nil {
+			c.logger.WithError(err).Warn("Failed to decode danmaku packet")
+			continue
+		}
+
+		for _, packet := range packets {
+			c.handlePacket(room, packet)
+		}
+	}
+}
+
+// authenticate sends the auth packet and waits for a successful reply
+func (c *DanmakuClient) authenticate(conn *websocket.Conn, roomID int, token string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"uid":      0,
+		"roomid":   roomID,
+		"protover": danmakuProtoBrotli,
+		"platform": "web",
+		"type":     2,
+		"key":      token,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build auth packet: %w", err)
+	}
+
+	if err := c.write(conn, encodeDanmakuPacket(danmakuOpAuth, danmakuProtoHeartbeat, body)); err != nil {
+		return fmt.Errorf("failed to send auth packet: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(danmakuAuthTimeout))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("failed to read auth reply: %w", err)
+	}
+
+	packets, err := decodeDanmakuPackets(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode auth reply: %w", err)
+	}
+
+	for _, packet := range packets {
+		if packet.Operation != danmakuOpAuthReply {
+			continue
+		}
+
+		var reply struct {
+			Code int `json:"code"`
+		}
+		if err := json.Unmarshal(packet.Body, &reply); err != nil {
+			return fmt.Errorf("failed to parse auth reply: %w", err)
+		}
+		if reply.Code != 0 {
+			return fmt.Errorf("auth rejected (code %d)", reply.Code)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unexpected packet while waiting for auth reply")
+}
+
+// heartbeat sends a heartbeat packet immediately and then on every interval
+func (c *DanmakuClient) heartbeat(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(c.heartbeatInterval)
+	defer ticker.Stop()
+
+	packet := encodeDanmakuPacket(danmakuOpHeartbeat, danmakuProtoHeartbeat, []byte("[object Object]"))
+	for {
+		if err := c.write(conn, packet); err != nil {
+			c.logger.WithError(err).Debug("Failed to send heartbeat")
+			conn.Close()
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// write serializes writes to the connection
+func (c *DanmakuClient) write(conn *websocket.Conn, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(danmakuAuthTimeout))
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// handlePacket processes a single decoded packet
+func (c *DanmakuClient) handlePacket(roomID string, packet danmakuPacket) {
+	switch packet.Operation {
+	case danmakuOpHeartbeatReply:
+		if len(packet.Body) >= 4 {
+			c.popularity.Store(int64(binary.BigEndian.Uint32(packet.Body[:4])))
+		}
+	case danmakuOpMessage:
+		event, ok := parseDanmakuMessage(roomID, packet.Body)
+		if !ok {
+			return
+		}
+		c.emit(event)
+	}
+}
+
+// emit publishes an event. Chat, gifts and super chats are dropped rather
+// than block the read loop when the channel is full, but status events wait
+// for room, since missing one would leave the room in the wrong state.
+func (c *DanmakuClient) emit(event DanmakuEvent) {
+	if event.Type.isStatus() {
+		select {
+		case c.events <- event:
+		case <-c.ctx.Done():
+		}
+		return
+	}
+
+	select {
+	case c.events <- event:
+	default:
+		c.logger.WithField("type", event.Type).Warn("Danmaku event channel full, dropping event")
+	}
+}
+
+// encodeDanmakuPacket builds a packet with the 16-byte protocol header
+func encodeDanmakuPacket(operation uint32, version uint16, body []byte) []byte {
+	buf := make([]byte, danmakuHeaderLength+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	binary.BigEndian.PutUint16(buf[4:6], danmakuHeaderLength)
+	binary.BigEndian.PutUint16(buf[6:8], version)
+	binary.BigEndian.PutUint32(buf[8:12], operation)
+	binary.BigEndian.PutUint32(buf[12:16], 1)
+	copy(buf[danmakuHeaderLength:], body)
+	return buf
+}
+
+// decodeDanmakuPackets splits a frame into packets, expanding compressed bodies
+func decodeDanmakuPackets(data []byte) ([]danmakuPacket, error) {
+	var packets []danmakuPacket
+
+	for len(data) > 0 {
+		if len(data) < danmakuHeaderLength {
+			return packets, fmt.Errorf("truncated packet header (%d bytes)", len(data))
+		}
+
+		packetLength := int(binary.BigEndian.Uint32(data[0:4]))
+		headerLength := int(binary.BigEndian.Uint16(data[4:6]))
+		if packetLength < headerLength || headerLength < danmakuHeaderLength || packetLength > len(data) {
+			return packets, fmt.Errorf("invalid packet length %d (header %d, available %d)", packetLength, headerLength, len(data))
+		}
+
+		packet := danmakuPacket{
+			Version:   binary.BigEndian.Uint16(data[6:8]),
+			Operation: binary.BigEndian.Uint32(data[8:12]),
+			Body:      data[headerLength:packetLength],
+		}
+		data = data[packetLength:]
+
+		var reader io.Reader
+		switch packet.Version {
+		case danmakuProtoZlib:
+			zr, err := zlib.NewReader(bytes.NewReader(packet.Body))
+			if err != nil {
+				return packets, fmt.Errorf("failed to open zlib body: %w", err)
+			}
+			reader = zr
+		case danmakuProtoBrotli:
+			reader = brotli.NewReader(bytes.NewReader(packet.Body))
+		default:
+			packets = append(packets, packet)
+			continue
+		}
+
+		inflated, err := io.ReadAll(reader)
+		if err != nil {
+			return packets, fmt.Errorf("failed to decompress body: %w", err)
+		}
+
+		nested, err := decodeDanmakuPackets(inflated)
+		packets = append(packets, nested...)
+		if err != nil {
+			return packets, err
+		}
+	}
+
+	return packets, nil
+}
+
+// parseDanmakuMessage converts a JSON message body into a typed event.
+// It returns false for commands the client does not publish.
+func parseDanmakuMessage(roomID string, body []byte) (DanmakuEvent, bool) {
+	var envelope struct {
+		Cmd   string            `json:"cmd"`
+		Info  []json.RawMessage `json:"info"`
+		Data  json.RawMessage   `json:"data"`
+		Round int               `json:"round"` // 1 on PREPARING when rotation starts
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return DanmakuEvent{}, false
+	}
+
+	// Commands may carry protocol suffixes, e.g. "DANMU_MSG:4:0:2:2:2:0"
+	cmd := envelope.Cmd
+	if idx := strings.Index(cmd, ":"); idx >= 0 {
+		cmd = cmd[:idx]
+	}
+
+	event := DanmakuEvent{
+		Type:      DanmakuEventType(cmd),
+		RoomID:    roomID,
+		Timestamp: time.Now(),
+		Raw:       json.RawMessage(body),
+	}
+
+	switch event.Type {
+	case DanmakuEventDanmaku:
+		msg, ok := parseDanmuInfo(envelope.Info)
+		if !ok {
+			return DanmakuEvent{}, false
+		}
+		event.Danmaku = msg
+	case DanmakuEventGift:
+		var data struct {
+			UID      int64  `json:"uid"`
+			UName    string `json:"uname"`
+			GiftName string `json:"giftName"`
+			Num      int    `json:"num"`
+			Price    int    `json:"price"`
+			CoinType string `json:"coin_type"`
+		}
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return DanmakuEvent{}, false
+		}
+		event.Gift = &GiftMessage{
+			UID:      data.UID,
+			UName:    data.UName,
+			GiftName: data.GiftName,
+			Num:      data.Num,
+			Price:    data.Price,
+			CoinType: data.CoinType,
+		}
+	case DanmakuEventSuperChat:
+		var data struct {
+			UID      int64  `json:"uid"`
+			Message  string `json:"message"`
+			Price    int    `json:"price"`
+			UserInfo struct {
+				UName string `json:"uname"`
+			} `json:"user_info"`
+		}
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return DanmakuEvent{}, false
+		}
+		event.SuperChat = &SuperChatMessage{
+			UID:     data.UID,
+			UName:   data.UserInfo.UName,
+			Message: data.Message,
+			Price:   data.Price,
+		}
+	case DanmakuEventRoomChange:
+		var data struct {
+			Title          string `json:"title"`
+			AreaName       string `json:"area_name"`
+			ParentAreaName string `json:"parent_area_name"`
+		}
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return DanmakuEvent{}, false
+		}
+		event.RoomChange = &RoomChangeMessage{
+			Title:          data.Title,
+			AreaName:       data.AreaName,
+			ParentAreaName: data.ParentAreaName,
+		}
+	case DanmakuEventPreparing:
+		event.Rotation = envelope.Round == 1
+	case DanmakuEventLive:
+		// Status messages carry no payload we need
+	default:
+		return DanmakuEvent{}, false
+	}
+
+	return event, true
+}
+
+// parseDanmuInfo extracts the sender and content from a DANMU_MSG info array
+func parseDanmuInfo(info []json.RawMessage) (*DanmakuMessage, bool) {
+	if len(info) < 3 {
+		return nil, false
+	}
+
+	msg := &DanmakuMessage{}
+	if err := json.Unmarshal(info[1], &msg.Content); err != nil {
+		return nil, false
+	}
+
+	var user []json.RawMessage
+	if err := json.Unmarshal(info[2], &user); err != nil || len(user) < 2 {
+		return nil, false
+	}
+	if err := json.Unmarshal(user[0], &msg.UID); err != nil {
+		return nil, false
+	}
+	if err := json.Unmarshal(user[1], &msg.UName); err != nil {
+		return nil, false
+	}
+
+	return msg, true
+}
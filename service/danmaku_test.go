@@ -0,0 +1,408 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// danmakuStandIn is a local WebSocket server speaking the live message protocol
+type danmakuStandIn struct {
+	server      *httptest.Server
+	connections atomic.Int32
+	heartbeats  atomic.Int32
+	authBodies  chan []byte
+	// onAuth is called after a successful auth reply for each connection
+	onAuth func(conn *websocket.Conn, n int32)
+	mu     sync.Mutex
+}
+
+func newDanmakuStandIn(t *testing.T, onAuth func(conn *websocket.Conn, n int32)) *danmakuStandIn {
+	s := &danmakuStandIn{
+		authBodies: make(chan []byte, 10),
+		onAuth:     onAuth,
+	}
+
+	upgrader := websocket.Upgrader{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := s.connections.Add(1)
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		packets, err := decodeDanmakuPackets(data)
+		if err != nil || len(packets) != 1 || packets[0].Operation != danmakuOpAuth {
+			return
+		}
+		s.authBodies <- packets[0].Body
+
+		s.mu.Lock()
+		conn.WriteMessage(websocket.BinaryMessage, encodeDanmakuPacket(danmakuOpAuthReply, danmakuProtoHeartbeat, []byte(`{"code":0}`)))
+		s.mu.Unlock()
+
+		if s.onAuth != nil {
+			go s.onAuth(conn, n)
+		}
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			packets, err := decodeDanmakuPackets(data)
+			if err != nil {
+				continue
+			}
+			for _, packet := range packets {
+				if packet.Operation == danmakuOpHeartbeat {
+					s.heartbeats.Add(1)
+					reply := make([]byte, 4)
+					binary.BigEndian.PutUint32(reply, 4242)
+					s.mu.Lock()
+					conn.WriteMessage(websocket.BinaryMessage, encodeDanmakuPacket(danmakuOpHeartbeatReply, danmakuProtoHeartbeat, reply))
+					s.mu.Unlock()
+				}
+			}
+		}
+	}))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+func (s *danmakuStandIn) url() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+func (s *danmakuStandIn) send(conn *websocket.Conn, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func newTestDanmakuClient(url string) *DanmakuClient {
+	client := NewDanmakuClient(func(ctx context.Context) (int, *DanmuInfo, error) {
+		return 5440, &DanmuInfo{Token: "test-token", URLs: []string{url}}, nil
+	})
+	client.heartbeatInterval = 50 * time.Millisecond
+	client.minBackoff = 10 * time.Millisecond
+	client.maxBackoff = 50 * time.Millisecond
+	return client
+}
+
+func zlibPacket(t *testing.T, inner ...[]byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	for _, p := range inner {
+		_, err := w.Write(p)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return encodeDanmakuPacket(danmakuOpMessage, danmakuProtoZlib, buf.Bytes())
+}
+
+func brotliPacket(t *testing.T, inner ...[]byte) []byte {
+	var buf bytes.Buffer
+	w := brotli.NewWriter(&buf)
+	for _, p := range inner {
+		_, err := w.Write(p)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return encodeDanmakuPacket(danmakuOpMessage, danmakuProtoBrotli, buf.Bytes())
+}
+
+func messagePacket(body string) []byte {
+	return encodeDanmakuPacket(danmakuOpMessage, danmakuProtoJSON, []byte(body))
+}
+
+func receiveEvent(t *testing.T, events <-chan DanmakuEvent) DanmakuEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "event channel closed unexpectedly")
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return DanmakuEvent{}
+	}
+}
+
+func TestEncodeDecodeDanmakuPacket(t *testing.T) {
+	t.Run("plain packet", func(t *testing.T) {
+		data := encodeDanmakuPacket(danmakuOpAuth, danmakuProtoHeartbeat, []byte(`{"roomid":1}`))
+		assert.Equal(t, uint32(len(data)), binary.BigEndian.Uint32(data[0:4]))
+		assert.Equal(t, uint16(danmakuHeaderLength), binary.BigEndian.Uint16(data[4:6]))
+
+		packets, err := decodeDanmakuPackets(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 1)
+		assert.Equal(t, uint32(danmakuOpAuth), packets[0].Operation)
+		assert.Equal(t, `{"roomid":1}`, string(packets[0].Body))
+	})
+
+	t.Run("zlib body with multiple packets", func(t *testing.T) {
+		data := zlibPacket(t, messagePacket(`{"cmd":"LIVE"}`), messagePacket(`{"cmd":"PREPARING"}`))
+
+		packets, err := decodeDanmakuPackets(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 2)
+		assert.Equal(t, `{"cmd":"LIVE"}`, string(packets[0].Body))
+		assert.Equal(t, `{"cmd":"PREPARING"}`, string(packets[1].Body))
+	})
+
+	t.Run("brotli body", func(t *testing.T) {
+		data := brotliPacket(t, messagePacket(`{"cmd":"LIVE"}`))
+
+		packets, err := decodeDanmakuPackets(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 1)
+		assert.Equal(t, `{"cmd":"LIVE"}`, string(packets[0].Body))
+	})
+
+	t.Run("truncated packet", func(t *testing.T) {
+		data := encodeDanmakuPacket(danmakuOpMessage, danmakuProtoJSON, []byte(`{}`))
+		_, err := decodeDanmakuPackets(data[:10])
+		assert.Error(t, err)
+
+		binary.BigEndian.PutUint32(data[0:4], 1000)
+		_, err = decodeDanmakuPackets(data)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseDanmakuMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		check  func(t *testing.T, event DanmakuEvent)
+		wantOK bool
+	}{
+		{
+			name:   "danmaku with cmd suffix",
+			body:   `{"cmd":"DANMU_MSG:4:0:2:2:2:0","info":[[0,1,25],"hello",[12345,"viewer",0]]}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				assert.Equal(t, DanmakuEventDanmaku, event.Type)
+				require.NotNil(t, event.Danmaku)
+				assert.Equal(t, int64(12345), event.Danmaku.UID)
+				assert.Equal(t, "viewer", event.Danmaku.UName)
+				assert.Equal(t, "hello", event.Danmaku.Content)
+			},
+		},
+		{
+			name:   "gift",
+			body:   `{"cmd":"SEND_GIFT","data":{"uid":1,"uname":"fan","giftName":"辣条","num":5,"price":100,"coin_type":"gold"}}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				require.NotNil(t, event.Gift)
+				assert.Equal(t, "辣条", event.Gift.GiftName)
+				assert.Equal(t, 5, event.Gift.Num)
+				assert.Equal(t, "gold", event.Gift.CoinType)
+			},
+		},
+		{
+			name:   "super chat",
+			body:   `{"cmd":"SUPER_CHAT_MESSAGE","data":{"uid":2,"message":"hi","price":30,"user_info":{"uname":"rich"}}}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				require.NotNil(t, event.SuperChat)
+				assert.Equal(t, "rich", event.SuperChat.UName)
+				assert.Equal(t, 30, event.SuperChat.Price)
+			},
+		},
+		{
+			name:   "room change",
+			body:   `{"cmd":"ROOM_CHANGE","data":{"title":"new title","area_name":"单机游戏","parent_area_name":"游戏"}}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				require.NotNil(t, event.RoomChange)
+				assert.Equal(t, "new title", event.RoomChange.Title)
+			},
+		},
+		{
+			name:   "live",
+			body:   `{"cmd":"LIVE","roomid":5440}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				assert.Equal(t, DanmakuEventLive, event.Type)
+			},
+		},
+		{
+			name:   "preparing",
+			body:   `{"cmd":"PREPARING","roomid":"5440"}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				assert.Equal(t, DanmakuEventPreparing, event.Type)
+				assert.False(t, event.Rotation)
+			},
+		},
+		{
+			name:   "preparing into rotation",
+			body:   `{"cmd":"PREPARING","round":1,"roomid":"5440"}`,
+			wantOK: true,
+			check: func(t *testing.T, event DanmakuEvent) {
+				assert.Equal(t, DanmakuEventPreparing, event.Type)
+				assert.True(t, event.Rotation)
+			},
+		},
+		{name: "unsupported cmd", body: `{"cmd":"INTERACT_WORD","data":{}}`},
+		{name: "malformed danmaku", body: `{"cmd":"DANMU_MSG","info":[]}`},
+		{name: "invalid json", body: `not json`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := parseDanmakuMessage("5440", []byte(tt.body))
+			assert.Equal(t, tt.wantOK, ok)
+			if ok {
+				assert.Equal(t, "5440", event.RoomID)
+				tt.check(t, event)
+			}
+		})
+	}
+}
+
+func TestDanmakuClient_ReceivesEvents(t *testing.T) {
+	var standIn *danmakuStandIn
+	standIn = newDanmakuStandIn(t, func(conn *websocket.Conn, n int32) {
+		standIn.send(conn, zlibPacket(t,
+			messagePacket(`{"cmd":"DANMU_MSG","info":[[0],"hello",[1,"viewer"]]}`),
+			messagePacket(`{"cmd":"LIVE","roomid":5440}`),
+		))
+		standIn.send(conn, brotliPacket(t,
+			messagePacket(`{"cmd":"SEND_GIFT","data":{"uid":1,"uname":"fan","giftName":"辣条","num":1}}`),
+		))
+		standIn.send(conn, messagePacket(`{"cmd":"PREPARING","roomid":"5440"}`))
+	})
+
+	client := newTestDanmakuClient(standIn.url())
+	client.Start()
+	defer client.Close()
+
+	var auth struct {
+		RoomID   int    `json:"roomid"`
+		Key      string `json:"key"`
+		Protover int    `json:"protover"`
+	}
+	select {
+	case body := <-standIn.authBodies:
+		require.NoError(t, json.Unmarshal(body, &auth))
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for auth packet")
+	}
+	assert.Equal(t, 5440, auth.RoomID)
+	assert.Equal(t, "test-token", auth.Key)
+	assert.Equal(t, danmakuProtoBrotli, auth.Protover)
+
+	events := client.Events()
+	assert.Equal(t, DanmakuEventDanmaku, receiveEvent(t, events).Type)
+	assert.Equal(t, DanmakuEventLive, receiveEvent(t, events).Type)
+	assert.Equal(t, DanmakuEventGift, receiveEvent(t, events).Type)
+	assert.Equal(t, DanmakuEventPreparing, receiveEvent(t, events).Type)
+	assert.True(t, client.Connected())
+
+	// Heartbeats are sent on the configured interval and replies update popularity
+	assert.Eventually(t, func() bool {
+		return standIn.heartbeats.Load() >= 2 && client.Popularity() == 4242
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestDanmakuClient_Reconnects(t *testing.T) {
+	var standIn *danmakuStandIn
+	standIn = newDanmakuStandIn(t, func(conn *websocket.Conn, n int32) {
+		if n == 1 {
+			// Drop the first connection right after auth
+			conn.Close()
+			return
+		}
+		standIn.send(conn, messagePacket(`{"cmd":"LIVE","roomid":5440}`))
+	})
+
+	client := newTestDanmakuClient(standIn.url())
+	client.Start()
+	defer client.Close()
+
+	event := receiveEvent(t, client.Events())
+	assert.Equal(t, DanmakuEventLive, event.Type)
+	assert.GreaterOrEqual(t, standIn.connections.Load(), int32(2))
+}
+
+func TestDanmakuClient_RetriesConnectErrors(t *testing.T) {
+	var attempts atomic.Int32
+	client := NewDanmakuClient(func(ctx context.Context) (int, *DanmuInfo, error) {
+		attempts.Add(1)
+		return 0, nil, assert.AnError
+	})
+	client.minBackoff = time.Millisecond
+	client.maxBackoff = 5 * time.Millisecond
+	client.Start()
+
+	assert.Eventually(t, func() bool { return attempts.Load() >= 3 }, 2*time.Second, 5*time.Millisecond)
+	assert.False(t, client.Connected())
+
+	client.Close()
+	_, ok := <-client.Events()
+	assert.False(t, ok, "event channel should be closed after Close")
+}
+
+func TestDanmakuClient_CloseWithoutStart(t *testing.T) {
+	client := newTestDanmakuClient("ws://127.0.0.1:0/sub")
+
+	assert.NotPanics(t, func() {
+		client.Close()
+		client.Close()
+	})
+	_, ok := <-client.Events()
+	assert.False(t, ok)
+}
+
+func TestDanmakuClient_EmitKeepsStatusEvents(t *testing.T) {
+	client := newTestDanmakuClient("ws://127.0.0.1:0/sub")
+	client.events = make(chan DanmakuEvent, 1)
+
+	// Chat is dropped once the channel is full
+	client.emit(DanmakuEvent{Type: DanmakuEventDanmaku})
+	client.emit(DanmakuEvent{Type: DanmakuEventDanmaku})
+
+	// A status event waits for the consumer instead
+	done := make(chan struct{})
+	go func() {
+		client.emit(DanmakuEvent{Type: DanmakuEventLive})
+		close(done)
+	}()
+
+	assert.Equal(t, DanmakuEventDanmaku, receiveEvent(t, client.Events()).Type)
+	assert.Equal(t, DanmakuEventLive, receiveEvent(t, client.Events()).Type)
+	<-done
+	assert.Empty(t, client.events)
+
+	// Closing the client releases a blocked status event
+	client.emit(DanmakuEvent{Type: DanmakuEventDanmaku})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		client.cancel()
+	}()
+	client.emit(DanmakuEvent{Type: DanmakuEventPreparing})
+	assert.Len(t, client.events, 1)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// DouyuService provides access to Douyu live streaming API
+type DouyuService struct {
+	RoomId     string
+	Client     *resty.Client // Web endpoints on www.douyu.com
+	OpenClient *resty.Client // Open API on open.douyucdn.cn
+	logger     *logrus.Entry
+}
+
+const (
+	douyuBaseURL       = "https://www.douyu.com"
+	douyuOpenAPIURL    = "https://open.douyucdn.cn"
+	douyuRoomAPI       = "api/RoomApi/room/"
+	douyuEncryptionURL = "wgapi/livenc/liveweb/websec/getEncryption"
+	douyuPlayURL       = "lapi/live/getH5PlayV1/"
+	// douyuDeviceID is the anonymous device ID the web player uses
+	douyuDeviceID = "10000000000000000000000000001501"
+)
+
+// chinaTimeZone is the time zone of timestamps returned by Chinese platforms
+var chinaTimeZone = time.FixedZone("CST", 8*60*60)
+
+// DouyuConfig holds platform-wide settings for Douyu rooms
+type DouyuConfig struct {
+	Proxy string `json:"proxy,omitempty"` // http, https or socks5 URL for API requests and stream pulls
+}
+
+// DouyuRoomInfo holds the room status and metadata returned by the Douyu open API
+type DouyuRoomInfo struct {
+	RoomID    string
+	Title     string
+	OwnerName string
+	Avatar    string
+	Thumb     string
+	IsLive    bool
+	StartTime time.Time
+}
+
+// douyuEncryption holds the parameters used to sign play URL requests
+type douyuEncryption struct {
+	Key       string `json:"key"`
+	RandStr   string `json:"rand_str"`
+	EncTime   int    `json:"enc_time"`
+	EncData   string `json:"enc_data"`
+	IsSpecial int    `json:"is_special"`
+}
+
+// ValidateDouyuRoomID validates the room ID format.
+// Douyu rooms can be addressed by number or by a custom alias.
+func ValidateDouyuRoomID(roomID string) error {
+	if roomID == "" {
+		return fmt.Errorf("room ID cannot be empty")
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9_]+$`).MatchString(roomID) {
+		return fmt.Errorf("room ID must contain only letters, digits or underscores")
+	}
+	if len(roomID) > 32 {
+		return fmt.Errorf("room ID is too long")
+	}
+	return nil
+}
+
+// NewDouyuService creates a new DouyuService instance with proper validation
+func NewDouyuService(roomId string) (*DouyuService, error) {
+	return NewDouyuServiceWithConfig(roomId, DouyuConfig{})
+}
+
+// NewDouyuServiceWithConfig creates a DouyuService that uses the platform settings
+func NewDouyuServiceWithConfig(roomId string, config DouyuConfig) (*DouyuService, error) {
+	if err := ValidateDouyuRoomID(roomId); err != nil {
+		return nil, fmt.Errorf("invalid room ID: %w", err)
+	}
+
+	newClient := func(base string) (*resty.Client, error) {
+		client := resty.New().
+			SetBaseURL(base).
+			SetHeader("User-Agent", userAgent).
+			SetTimeout(requestTimeout).
+			SetRetryCount(maxRetryCount).
+			SetRetryWaitTime(retryWaitTime)
+		return client, applyProxy(client, config.Proxy)
+	}
+
+	client, err := newClient(douyuBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	openClient, err := newClient(douyuOpenAPIURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DouyuService{
+		RoomId:     roomId,
+		Client:     client,
+		OpenClient: openClient,
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "service",
+			"platform":  "douyu",
+			"room_id":   roomId,
+		}),
+	}, nil
+}
+
+// GetDouyuRoomInfo retrieves the room status and metadata
+func (d *DouyuService) GetDouyuRoomInfo() (*DouyuRoomInfo, error) {
+	resp, err := d.OpenClient.R().Get(douyuRoomAPI + d.RoomId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room info: %w", err)
+	}
+
+	var data struct {
+		Error int             `json:"error"`
+		Data  json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// On errors data holds the message as a string
+	if data.Error != 0 {
+		var msg string
+		_ = json.Unmarshal(data.Data, &msg)
+		return nil, fmt.Errorf("API error (code %d): %s", data.Error, msg)
+	}
+
+	var room struct {
+		RoomID     string `json:"room_id"`
+		RoomName   string `json:"room_name"`
+		OwnerName  string `json:"owner_name"`
+		Avatar     string `json:"avatar"`
+		RoomThumb  string `json:"room_thumb"`
+		RoomStatus string `json:"room_status"` // "1" live, "2" offline
+		StartTime  string `json:"start_time"`  // Format: "YYYY-MM-DD HH:mm"
+	}
+
+	if err := json.Unmarshal(data.Data, &room); err != nil {
+		return nil, fmt.Errorf("failed to parse room data: %w", err)
+	}
+
+	info := &DouyuRoomInfo{
+		RoomID:    room.RoomID,
+		Title:     room.RoomName,
+		OwnerName: room.OwnerName,
+		Avatar:    room.Avatar,
+		Thumb:     room.RoomThumb,
+		IsLive:    room.RoomStatus == "1",
+	}
+
+	if room.StartTime != "" {
+		parsedTime, err := time.ParseInLocation("2006-01-02 15:04", room.StartTime, chinaTimeZone)
+		if err != nil {
+			d.logger.WithError(err).WithField("start_time", room.StartTime).Warn("Failed to parse start time")
+		} else {
+			info.StartTime = parsedTime
+		}
+	}
+
+	return info, nil
+}
+
+// GetDouyuLiveStatus retrieves the live status of the room
+func (d *DouyuService) GetDouyuLiveStatus() (bool, error) {
+	info, err := d.GetDouyuRoomInfo()
+	if err != nil {
+		return false, fmt.Errorf("failed to get live status: %w", err)
+	}
+
+	status := "offline"
+	if info.IsLive {
+		status = "live"
+	}
+	d.logger.WithFields(logrus.Fields{
+		"room_id": d.RoomId,
+		"status":  status,
+	}).Info("Room status check completed")
+	return info.IsLive, nil
+}
+
+// GetDouyuLiveRealURL retrieves the live stream URL of a room by its numeric ID.
+// Douyu requires play URL requests to be signed with parameters from the
+// encryption endpoint.
+func (d *DouyuService) GetDouyuLiveRealURL(realRoomId string) (string, error) {
+	if err := validateRoomID(realRoomId); err != nil {
+		return "", fmt.Errorf("invalid real room ID: %w", err)
+	}
+
+	resp, err := d.Client.R().
+		SetQueryParam("did", douyuDeviceID).
+		Get(douyuEncryptionURL)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get encryption parameters: %w", err)
+	}
+
+	var encData struct {
+		Error int             `json:"error"`
+		Msg   string          `json:"msg"`
+		Data  douyuEncryption `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &encData); err != nil {
+		return "", fmt.Errorf("failed to parse encryption response: %w", err)
+	}
+
+	if encData.Error != 0 {
+		return "", fmt.Errorf("encryption API error (code %d): %s", encData.Error, encData.Msg)
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	params := douyuSign(encData.Data, realRoomId, douyuDeviceID, ts)
+	params["cdn"] = ""
+	params["rate"] = "0" // Original quality
+	params["hevc"] = "0"
+	params["fa"] = "0"
+	params["ive"] = "0"
+
+	resp, err = d.Client.R().
+		SetFormData(params).
+		Post(douyuPlayURL + realRoomId)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get play URL: %w", err)
+	}
+
+	var playData struct {
+		Error int             `json:"error"`
+		Msg   string          `json:"msg"`
+		Data  json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &playData); err != nil {
+		return "", fmt.Errorf("failed to parse play URL response: %w", err)
+	}
+
+	// data is an empty string when the request fails, e.g. for offline rooms
+	if playData.Error != 0 {
+		return "", fmt.Errorf("play URL API error (code %d): %s", playData.Error, playData.Msg)
+	}
+
+	var stream struct {
+		RTMPURL  string `json:"rtmp_url"`
+		RTMPLive string `json:"rtmp_live"`
+	}
+
+	if err := json.Unmarshal(playData.Data, &stream); err != nil {
+		return "", fmt.Errorf("failed to parse play URL data: %w", err)
+	}
+
+	if stream.RTMPURL == "" || stream.RTMPLive == "" {
+		return "", fmt.Errorf("no live stream URL found for room %s", realRoomId)
+	}
+
+	return stream.RTMPURL + "/" + stream.RTMPLive, nil
+}
+
+// douyuSign computes the signed form parameters for a play URL request
+func douyuSign(enc douyuEncryption, roomID, did, ts string) map[string]string {
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	secret := enc.RandStr
+	for i := 0; i < enc.EncTime; i++ {
+		secret = md5Hex(secret + enc.Key)
+	}
+
+	salt := roomID + ts
+	if enc.IsSpecial == 1 {
+		salt = ""
+	}
+
+	return map[string]string{
+		"enc_data": enc.EncData,
+		"tt":       ts,
+		"did":      did,
+		"auth":     md5Hex(secret + enc.Key + salt),
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Recorded Douyu responses, trimmed to the fields we use
+const (
+	douyuRoomLiveResponse    = `{"error":0,"data":{"room_id":"288016","room_thumb":"https://rpic.douyucdn.cn/asrpic/288016.png","cate_id":"1","room_name":"今晚不睡觉","room_status":"1","start_time":"2024-03-01 20:05","owner_name":"测试主播","avatar":"https://apic.douyucdn.cn/upload/avatar.jpg","online":1234}}`
+	douyuRoomOfflineResponse = `{"error":0,"data":{"room_id":"288016","room_thumb":"https://rpic.douyucdn.cn/asrpic/288016.png","room_name":"今晚不睡觉","room_status":"2","start_time":"2024-03-01 20:05","owner_name":"测试主播","avatar":""}}`
+	douyuRoomMissingResponse = `{"error":101,"data":"房间未找到"}`
+	douyuEncryptionResponse  = `{"error":0,"msg":"","data":{"key":"testkey","rand_str":"a1b2c3","enc_time":2,"enc_data":"ENCDATA","is_special":0}}`
+	douyuPlayResponse        = `{"error":0,"msg":"ok","data":{"room_id":288016,"rtmp_url":"https://hw-tct.douyucdn.cn/live","rtmp_live":"288016rEaldxsN.flv?wsAuth=abc&token=web-h5-0-288016","rate":0}}`
+	douyuPlayOfflineResponse = `{"error":-5,"msg":"房间未开播","data":""}`
+)
+
+// newDouyuStandIn starts a server serving recorded responses and points the service at it
+func newDouyuStandIn(t *testing.T, routes map[string]func(w http.ResponseWriter, r *http.Request)) *DouyuService {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, handler := range routes {
+		mux.HandleFunc(path, handler)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	svc, err := NewDouyuService("288016")
+	require.NoError(t, err)
+	svc.Client.SetBaseURL(server.URL).SetRetryCount(0)
+	svc.OpenClient.SetBaseURL(server.URL).SetRetryCount(0)
+	return svc
+}
+
+func douyuResponse(body string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestNewDouyuService(t *testing.T) {
+	tests := []struct {
+		name    string
+		roomID  string
+		wantErr bool
+	}{
+		{"numeric ID", "288016", false},
+		{"alias", "qiezi_666", false},
+		{"empty", "", true},
+		{"invalid characters", "room/1", true},
+		{"too long", "123456789012345678901234567890123", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, err := NewDouyuService(tt.roomID)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, svc)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, douyuBaseURL, svc.Client.BaseURL)
+			assert.Equal(t, douyuOpenAPIURL, svc.OpenClient.BaseURL)
+		})
+	}
+}
+
+func TestDouyuService_GetDouyuRoomInfo(t *testing.T) {
+	t.Run("live room", func(t *testing.T) {
+		svc := newDouyuStandIn(t, map[string]func(w http.ResponseWriter, r *http.Request){
+			"/api/RoomApi/room/288016": douyuResponse(douyuRoomLiveResponse),
+		})
+
+		info, err := svc.GetDouyuRoomInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "288016", info.RoomID)
+		assert.Equal(t, "今晚不睡觉", info.Title)
+		assert.Equal(t, "测试主播", info.OwnerName)
+		assert.Equal(t, "https://rpic.douyucdn.cn/asrpic/288016.png", info.Thumb)
+		assert.True(t, info.IsLive)
+		assert.Equal(t, time.Date(2024, 3, 1, 20, 5, 0, 0, chinaTimeZone).Unix(), info.StartTime.Unix())
+
+		isLive, err := svc.GetDouyuLiveStatus()
+		require.NoError(t, err)
+		assert.True(t, isLive)
+	})
+
+	t.Run("offline room", func(t *testing.T) {
+		svc := newDouyuStandIn(t, map[string]func(w http.ResponseWriter, r *http.Request){
+			"/api/RoomApi/room/288016": douyuResponse(douyuRoomOfflineResponse),
+		})
+
+		isLive, err := svc.GetDouyuLiveStatus()
+		require.NoError(t, err)
+		assert.False(t, isLive)
+	})
+
+	t.Run("missing room", func(t *testing.T) {
+		svc := newDouyuStandIn(t, map[string]func(w http.ResponseWriter, r *http.Request){
+			"/api/RoomApi/room/288016": douyuResponse(douyuRoomMissingResponse),
+		})
+
+		_, err := svc.GetDouyuLiveStatus()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "code 101")
+		assert.Contains(t, err.Error(), "房间未找到")
+	})
+}
+
+func TestDouyuService_GetDouyuLiveRealURL(t *testing.T) {
+	t.Run("signed request", func(t *testing.T) {
+		var form map[string]string
+		svc := newDouyuStandIn(t, map[string]func(w http.ResponseWriter, r *http.Request){
+			"/wgapi/livenc/liveweb/websec/getEncryption": func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, douyuDeviceID, r.URL.Query().Get("did"))
+				douyuResponse(douyuEncryptionResponse)(w, r)
+			},
+			"/lapi/live/getH5PlayV1/288016": func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				require.NoError(t, r.ParseForm())
+				form = map[string]string{}
+				for key := range r.PostForm {
+					form[key] = r.PostForm.Get(key)
+				}
+				douyuResponse(douyuPlayResponse)(w, r)
+			},
+		})
+
+		playURL, err := svc.GetDouyuLiveRealURL("288016")
+		require.NoError(t, err)
+		assert.Equal(t, "https://hw-tct.douyucdn.cn/live/288016rEaldxsN.flv?wsAuth=abc&token=web-h5-0-288016", playURL)
+
+		assert.Equal(t, "ENCDATA", form["enc_data"])
+		assert.Equal(t, douyuDeviceID, form["did"])
+		assert.Equal(t, "0", form["rate"])
+		assert.Equal(t, douyuSign(douyuEncryption{Key: "testkey", RandStr: "a1b2c3", EncTime: 2, EncData: "ENCDATA"}, "288016", douyuDeviceID, form["tt"])["auth"], form["auth"])
+	})
+
+	t.Run("offline room", func(t *testing.T) {
+		svc := newDouyuStandIn(t, map[string]func(w http.ResponseWriter, r *http.Request){
+			"/wgapi/livenc/liveweb/websec/getEncryption": douyuResponse(douyuEncryptionResponse),
+			"/lapi/live/getH5PlayV1/288016":              douyuResponse(douyuPlayOfflineResponse),
+		})
+
+		_, err := svc.GetDouyuLiveRealURL("288016")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "code -5")
+	})
+
+	t.Run("alias is rejected", func(t *testing.T) {
+		svc := newDouyuStandIn(t, nil)
+		_, err := svc.GetDouyuLiveRealURL("qiezi_666")
+		assert.Error(t, err)
+	})
+}
+
+func TestDouyuSign(t *testing.T) {
+	enc := douyuEncryption{Key: "testkey", RandStr: "a1b2c3", EncTime: 2, EncData: "ENCDATA"}
+
+	params := douyuSign(enc, "288016", douyuDeviceID, "1700000000")
+	assert.Equal(t, "0e7aa72b0607bcca4dffdf433e0819c4", params["auth"])
+	assert.Equal(t, "ENCDATA", params["enc_data"])
+	assert.Equal(t, "1700000000", params["tt"])
+	assert.Equal(t, douyuDeviceID, params["did"])
+
+	// Special rooms are signed without the room ID and timestamp salt
+	enc.IsSpecial = 1
+	params = douyuSign(enc, "288016", douyuDeviceID, "1700000000")
+	assert.Equal(t, "ee9038c15759a2c46c34bad2e1a4757b", params["auth"])
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Recorded Huya profileRoom responses, trimmed to the fields we use
+const (
+	huyaRecordedAntiCode = "wsSecret=0a1b2c&wsTime=6553f000&fm=RFdxOEJjSjNoNkRKdDZUWV8kMF8kMV8kMl8kMw%3D%3D&ctype=huya_live&fs=bgct&t=100"
+
+	huyaRoomLiveResponse = `{"status":200,"message":"","data":{"liveStatus":"ON",` +
+		`"profileInfo":{"uid":1199512345678,"nick":"虎牙主播","avatar180":"https://huyaimg.msstatic.com/avatar.jpg"},` +
+		`"liveData":{"profileRoom":660000,"introduction":"周末开黑","screenshot":"https://live-cover.msstatic.com/cover.jpg","startTime":1709294400},` +
+		`"stream":{"baseSteamInfoList":[` +
+		`{"sCdnType":"AL","sStreamName":"1234-abc","sFlvUrl":"https://al.flv.huya.com/src","sFlvUrlSuffix":"flv","sFlvAntiCode":"` + huyaRecordedAntiCode + `","sHlsUrl":"https://al.hls.huya.com/src","sHlsUrlSuffix":"m3u8","sHlsAntiCode":"` + huyaRecordedAntiCode + `"},` +
+		`{"sCdnType":"TX","sStreamName":"1234-abc","sFlvUrl":"https://tx.flv.huya.com/src","sFlvUrlSuffix":"flv","sFlvAntiCode":"` + huyaRecordedAntiCode + `","sHlsUrl":"","sHlsUrlSuffix":"m3u8","sHlsAntiCode":""}` +
+		`]}}}`
+	huyaRoomReplayResponse  = `{"status":200,"message":"","data":{"liveStatus":"REPLAY","profileInfo":{"uid":1199512345678,"nick":"虎牙主播"},"liveData":{"profileRoom":660000,"introduction":"回放"},"stream":null}}`
+	huyaRoomMissingResponse = `{"status":422,"message":"该主播不存在！","data":""}`
+)
+
+// newHuyaStandIn starts a server serving a recorded response and points the service at it
+func newHuyaStandIn(t *testing.T, body string) *HuyaService {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/cache.php", r.URL.Path)
+		assert.Equal(t, "profileRoom", r.URL.Query().Get("do"))
+		assert.Equal(t, "660000", r.URL.Query().Get("roomid"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := NewHuyaService("660000")
+	require.NoError(t, err)
+	svc.Client.SetBaseURL(server.URL).SetRetryCount(0)
+	return svc
+}
+
+func TestNewHuyaService(t *testing.T) {
+	svc, err := NewHuyaService("660000")
+	require.NoError(t, err)
+	assert.Equal(t, huyaBaseURL, svc.Client.BaseURL)
+
+	_, err = NewHuyaService("lpl")
+	assert.NoError(t, err)
+
+	_, err = NewHuyaService("")
+	assert.Error(t, err)
+
+	_, err = NewHuyaService("https://www.huya.com/660000")
+	assert.Error(t, err)
+}
+
+func TestHuyaService_GetHuyaRoomInfo(t *testing.T) {
+	t.Run("live room", func(t *testing.T) {
+		svc := newHuyaStandIn(t, huyaRoomLiveResponse)
+
+		info, err := svc.GetHuyaRoomInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "660000", info.RoomID)
+		assert.Equal(t, "1199512345678", info.UID)
+		assert.Equal(t, "虎牙主播", info.Nick)
+		assert.Equal(t, "周末开黑", info.Title)
+		assert.Equal(t, "https://live-cover.msstatic.com/cover.jpg", info.Screenshot)
+		assert.True(t, info.IsLive)
+		assert.Equal(t, int64(1709294400), info.StartTime.Unix())
+		assert.Len(t, info.Streams, 2)
+
+		isLive, err := svc.GetHuyaLiveStatus()
+		require.NoError(t, err)
+		assert.True(t, isLive)
+	})
+
+	t.Run("replay is offline", func(t *testing.T) {
+		svc := newHuyaStandIn(t, huyaRoomReplayResponse)
+
+		isLive, err := svc.GetHuyaLiveStatus()
+		require.NoError(t, err)
+		assert.False(t, isLive)
+
+		_, err = svc.GetHuyaLiveRealURL("flv")
+		assert.ErrorContains(t, err, "not live")
+	})
+
+	t.Run("missing room", func(t *testing.T) {
+		svc := newHuyaStandIn(t, huyaRoomMissingResponse)
+
+		_, err := svc.GetHuyaLiveStatus()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "code 422")
+	})
+}
+
+func TestHuyaService_GetHuyaLiveRealURL(t *testing.T) {
+	svc := newHuyaStandIn(t, huyaRoomLiveResponse)
+
+	flvURLs, err := svc.GetHuyaLiveRealURL("flv")
+	require.NoError(t, err)
+	require.Len(t, flvURLs, 2)
+	assert.True(t, strings.HasPrefix(flvURLs[0], "https://al.flv.huya.com/src/1234-abc.flv?"))
+	assert.True(t, strings.HasPrefix(flvURLs[1], "https://tx.flv.huya.com/src/1234-abc.flv?"))
+
+	query, err := url.ParseQuery(strings.SplitN(flvURLs[0], "?", 2)[1])
+	require.NoError(t, err)
+	assert.Equal(t, "huya_live", query.Get("ctype"))
+	assert.Equal(t, "bgct", query.Get("fs"))
+	assert.Len(t, query.Get("wsSecret"), 32)
+	assert.NotEqual(t, "0a1b2c", query.Get("wsSecret"))
+
+	// CDNs without an HLS stream are skipped
+	hlsURLs, err := svc.GetHuyaLiveRealURL("hls")
+	require.NoError(t, err)
+	require.Len(t, hlsURLs, 1)
+	assert.True(t, strings.HasPrefix(hlsURLs[0], "https://al.hls.huya.com/src/1234-abc.m3u8?"))
+
+	_, err = svc.GetHuyaLiveRealURL("dash")
+	assert.Error(t, err)
+}
+
+func TestHuyaAntiCode(t *testing.T) {
+	now := time.UnixMilli(1700000012345)
+
+	query, err := huyaAntiCode(huyaRecordedAntiCode, "1234-abc", 1400000000001, now)
+	require.NoError(t, err)
+
+	params, err := url.ParseQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, "4e282e67b49aaef8d30fcf6a96429149", params.Get("wsSecret"))
+	assert.Equal(t, "6553f17a", params.Get("wsTime"))
+	assert.Equal(t, "3100000012346", params.Get("seqid"))
+	assert.Equal(t, "12345000", params.Get("uuid"))
+	assert.Equal(t, "1400000000001", params.Get("u"))
+	assert.Equal(t, "1700000012345", params.Get("sdk_sid"))
+	assert.Equal(t, "huya_live", params.Get("ctype"))
+	assert.Equal(t, "bgct", params.Get("fs"))
+
+	_, err = huyaAntiCode("wsSecret=abc", "1234-abc", 1400000000001, now)
+	assert.Error(t, err)
+
+	_, err = huyaAntiCode("ctype=huya_live&fm=not-base64!", "1234-abc", 1400000000001, now)
+	assert.Error(t, err)
+}
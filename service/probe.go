@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	probeTimeout = 10 * time.Second
+	// probeReadLimit bounds how much of a playlist or stream is read while probing
+	probeReadLimit = 64 * 1024
+)
+
+// defaultPorts are used for TCP checks when a URL has no explicit port
+var defaultPorts = map[string]string{
+	"rtmp":  "1935",
+	"rtmps": "443",
+	"rtsp":  "554",
+}
+
+// StreamProber checks whether a raw stream URL is currently serving media.
+// HTTP URLs are fetched directly; other protocols are checked with ffprobe
+// when it is available, or with a TCP connect otherwise.
+type StreamProber struct {
+	HTTPClient  *http.Client
+	FFprobePath string // Empty disables ffprobe and falls back to a TCP connect
+	Timeout     time.Duration
+}
+
+// NewStreamProber creates a prober that uses ffprobe from PATH if installed
+func NewStreamProber() *StreamProber {
+	ffprobe, _ := exec.LookPath("ffprobe")
+	return &StreamProber{
+		HTTPClient:  &http.Client{Timeout: probeTimeout},
+		FFprobePath: ffprobe,
+		Timeout:     probeTimeout,
+	}
+}
+
+// URLConfig holds platform-wide settings for raw stream URLs
+type URLConfig struct {
+	// Proxy is an http, https or socks5 URL used for HTTP(S) stream URLs.
+	// RTMP and RTSP URLs are always probed directly.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// SetProxy routes HTTP probes through the proxy. An empty proxy keeps the
+// default, which honours HTTP_PROXY and friends.
+func (p *StreamProber) SetProxy(raw string) error {
+	u, err := ParseProxy(raw)
+	if err != nil || u == nil {
+		return err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	p.HTTPClient.Transport = transport
+	return nil
+}
+
+// SupportedProbeScheme reports whether URLs with the scheme can be probed
+func SupportedProbeScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		return true
+	default:
+		_, ok := defaultPorts[strings.ToLower(scheme)]
+		return ok
+	}
+}
+
+// Probe reports whether the stream is live. An error means the state
+// could not be determined, e.g. on DNS failures or timeouts; a server
+// that refuses connections or answers with an error status is offline.
+func (p *StreamProber) Probe(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, fmt.Errorf("invalid stream URL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return p.probeHTTP(ctx, u)
+	default:
+		if !SupportedProbeScheme(u.Scheme) {
+			return false, fmt.Errorf("unsupported stream URL scheme %q", u.Scheme)
+		}
+		if p.FFprobePath != "" {
+			return p.probeFFprobe(ctx, u)
+		}
+		return p.probeTCP(ctx, u)
+	}
+}
+
+// probeHTTP fetches an HLS playlist or the start of an HTTP stream such as FLV
+func (p *StreamProber) probeHTTP(ctx context.Context, u *url.URL) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return probeNetworkResult(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, nil
+	}
+
+	body := bufio.NewReader(io.LimitReader(resp.Body, probeReadLimit))
+	head, _ := body.Peek(7)
+
+	isPlaylist := strings.HasSuffix(strings.ToLower(u.Path), ".m3u8") ||
+		strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "mpegurl") ||
+		bytes.HasPrefix(head, []byte("#EXTM3U"))
+	if isPlaylist {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return probeNetworkResult(err)
+		}
+		return hlsPlaylistLive(data), nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(u.Path), ".flv") {
+		return bytes.HasPrefix(head, []byte("FLV")), nil
+	}
+
+	// Any other stream counts as live once it starts sending data
+	return len(head) > 0, nil
+}
+
+// hlsPlaylistLive reports whether a playlist has segments or variant streams
+func hlsPlaylistLive(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("#EXTM3U")) {
+		return false
+	}
+	return bytes.Contains(data, []byte("#EXTINF")) || bytes.Contains(data, []byte("#EXT-X-STREAM-INF"))
+}
+
+// probeFFprobe asks ffprobe for the stream's codecs
+func (p *StreamProber) probeFFprobe(ctx context.Context, u *url.URL) (bool, error) {
+	args := []string{"-v", "error", "-show_entries", "stream=codec_type", "-of", "csv=p=0", u.String()}
+	if strings.EqualFold(u.Scheme, "rtmp") || strings.EqualFold(u.Scheme, "rtmps") {
+		args = append([]string{"-rw_timeout", fmt.Sprint(p.Timeout.Microseconds())}, args...)
+	}
+
+	output, err := exec.CommandContext(ctx, p.FFprobePath, args...).Output()
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("ffprobe timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// ffprobe could not open the stream
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// probeTCP checks that the server accepts connections. It cannot tell
+// whether the server is publishing the stream.
+func (p *StreamProber) probeTCP(ctx context.Context, u *url.URL) (bool, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPorts[strings.ToLower(u.Scheme)])
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return probeNetworkResult(err)
+	}
+	conn.Close()
+	return true, nil
+}
+
+// probeNetworkResult maps a refused connection to offline and other
+// network errors to an unknown state
+func probeNetworkResult(err error) (bool, error) {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to probe stream: %w", err)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProber() *StreamProber {
+	return &StreamProber{
+		HTTPClient: &http.Client{Timeout: time.Second},
+		Timeout:    time.Second,
+	}
+}
+
+func TestStreamProber_HTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/live/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.0,\nseg1.ts\n"))
+	})
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\nlow.m3u8\n"))
+	})
+	mux.HandleFunc("/empty.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n"))
+	})
+	mux.HandleFunc("/playlist", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:2.0,\nseg1.ts\n"))
+	})
+	mux.HandleFunc("/live/stream.flv", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("FLV\x01\x05\x00\x00\x00\x09"))
+	})
+	mux.HandleFunc("/broken.flv", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>not a stream</html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		live bool
+	}{
+		{"/live/index.m3u8", true},
+		{"/master.m3u8", true},
+		{"/empty.m3u8", false},
+		{"/playlist", true},
+		{"/live/stream.flv", true},
+		{"/broken.flv", false},
+		{"/missing.m3u8", false},
+	}
+
+	prober := newTestProber()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			live, err := prober.Probe(context.Background(), server.URL+tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.live, live)
+		})
+	}
+}
+
+func TestStreamProber_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+
+	prober := newTestProber()
+
+	live, err := prober.Probe(context.Background(), "rtmp://"+addr+"/live/main")
+	require.NoError(t, err)
+	assert.True(t, live)
+
+	// A closed port means the server is not running
+	listener.Close()
+	live, err = prober.Probe(context.Background(), "rtmp://"+addr+"/live/main")
+	require.NoError(t, err)
+	assert.False(t, live)
+}
+
+func TestStreamProber_Errors(t *testing.T) {
+	prober := newTestProber()
+
+	_, err := prober.Probe(context.Background(), "ftp://example.com/stream")
+	assert.ErrorContains(t, err, "unsupported stream URL scheme")
+
+	_, err = prober.Probe(context.Background(), "http://[::1")
+	assert.Error(t, err)
+
+	// A timeout leaves the state unknown
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	prober.Timeout = 100 * time.Millisecond
+	_, err = prober.Probe(context.Background(), server.URL+"/live.m3u8")
+	assert.Error(t, err)
+}
+
+func TestSupportedProbeScheme(t *testing.T) {
+	for _, scheme := range []string{"http", "HTTPS", "rtmp", "rtmps", "rtsp"} {
+		assert.True(t, SupportedProbeScheme(scheme), scheme)
+	}
+	assert.False(t, SupportedProbeScheme("ftp"))
+	assert.False(t, SupportedProbeScheme("srt"))
+	assert.False(t, SupportedProbeScheme(""))
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nick3/restreamer_monitor_go/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	twitchHelixURL = "https://api.twitch.tv/helix"
+	twitchAuthURL  = "https://id.twitch.tv"
+	twitchGQLURL   = "https://gql.twitch.tv/gql"
+	twitchUsherURL = "https://usher.ttvnw.net"
+	// twitchWebClientID is the public client ID of the Twitch web player,
+	// required by the GQL playback token endpoint
+	twitchWebClientID = "kimne78kx3ncx6brgo4mv6wki5h1ko"
+	// twitchTokenRefreshMargin renews app access tokens before they expire
+	twitchTokenRefreshMargin = time.Minute
+)
+
+// twitchPlaybackQuery requests a playback access token for a live channel
+const twitchPlaybackQuery = `query PlaybackAccessToken_Template($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!) {` +
+	` streamPlaybackAccessToken(channelName: $login, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isLive) { value signature __typename }` +
+	` videoPlaybackAccessToken(id: $vodID, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isVod) { value signature __typename }` +
+	`}`
+
+// TwitchConfig holds the app credentials and API endpoints for Twitch.
+// Empty URLs fall back to the public Twitch endpoints.
+type TwitchConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	HelixURL     string `json:"helix_url,omitempty"`
+	AuthURL      string `json:"auth_url,omitempty"`
+	GQLURL       string `json:"gql_url,omitempty"`
+	UsherURL     string `json:"usher_url,omitempty"`
+	Proxy        string `json:"proxy,omitempty"` // http, https or socks5 URL for API requests and stream pulls
+}
+
+// TwitchService provides access to the Twitch Helix API and playback endpoints
+type TwitchService struct {
+	Login  string
+	Client *resty.Client
+	config TwitchConfig
+	logger *logrus.Entry
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// TwitchStream is a live stream returned by Helix
+type TwitchStream struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	UserName     string    `json:"user_name"`
+	GameName     string    `json:"game_name"`
+	Type         string    `json:"type"`
+	Title        string    `json:"title"`
+	ViewerCount  int       `json:"viewer_count"`
+	StartedAt    time.Time `json:"started_at"`
+	ThumbnailURL string    `json:"thumbnail_url"` // Contains {width} and {height} placeholders
+}
+
+// TwitchUser is a user returned by Helix
+type TwitchUser struct {
+	ID              string `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	OfflineImageURL string `json:"offline_image_url"`
+}
+
+// ValidateTwitchLogin validates the channel login format
+func ValidateTwitchLogin(login string) error {
+	if login == "" {
+		return fmt.Errorf("login cannot be empty")
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9_]{1,25}$`).MatchString(login) {
+		return fmt.Errorf("login must be 1-25 letters, digits or underscores")
+	}
+	return nil
+}
+
+// NewTwitchService creates a new TwitchService for a channel login
+func NewTwitchService(login string, config TwitchConfig) (*TwitchService, error) {
+	if err := ValidateTwitchLogin(login); err != nil {
+		return nil, fmt.Errorf("invalid login: %w", err)
+	}
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, fmt.Errorf("twitch client_id and client_secret are required")
+	}
+
+	if config.HelixURL == "" {
+		config.HelixURL = twitchHelixURL
+	}
+	if config.AuthURL == "" {
+		config.AuthURL = twitchAuthURL
+	}
+	if config.GQLURL == "" {
+		config.GQLURL = twitchGQLURL
+	}
+	if config.UsherURL == "" {
+		config.UsherURL = twitchUsherURL
+	}
+
+	client := resty.New().
+		SetHeader("User-Agent", userAgent).
+		SetTimeout(requestTimeout).
+		SetRetryCount(maxRetryCount).
+		SetRetryWaitTime(retryWaitTime)
+	if err := applyProxy(client, config.Proxy); err != nil {
+		return nil, err
+	}
+
+	return &TwitchService{
+		Login:  strings.ToLower(login),
+		Client: client,
+		config: config,
+		logger: logger.GetLogger(map[string]interface{}{
+			"component": "service",
+			"platform":  "twitch",
+			"room_id":   login,
+		}),
+	}, nil
+}
+
+// appAccessToken returns a cached app access token, requesting a new one
+// with the client credentials grant when needed
+func (t *TwitchService) appAccessToken(forceRefresh bool) (string, error) {
+	t.tokenMu.Lock()
+	defer t.tokenMu.Unlock()
+
+	if !forceRefresh && t.token != "" && time.Now().Before(t.tokenExpiry) {
+		return t.token, nil
+	}
+
+	resp, err := t.Client.R().
+		SetFormData(map[string]string{
+			"client_id":     t.config.ClientID,
+			"client_secret": t.config.ClientSecret,
+			"grant_type":    "client_credentials",
+		}).
+		Post(t.config.AuthURL + "/oauth2/token")
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get app access token: %w", err)
+	}
+
+	var data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Message     string `json:"message"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK || data.AccessToken == "" {
+		return "", fmt.Errorf("token API error (status %d): %s", resp.StatusCode(), data.Message)
+	}
+
+	t.token = data.AccessToken
+	t.tokenExpiry = time.Now().Add(time.Duration(data.ExpiresIn)*time.Second - twitchTokenRefreshMargin)
+	return t.token, nil
+}
+
+// helixGet calls a Helix endpoint and decodes its data array into out.
+// An expired token is renewed once.
+func (t *TwitchService) helixGet(endpoint string, params map[string]string, out interface{}) error {
+	var resp *resty.Response
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := t.appAccessToken(attempt > 0)
+		if err != nil {
+			return err
+		}
+
+		resp, err = t.Client.R().
+			SetHeader("Client-Id", t.config.ClientID).
+			SetAuthToken(token).
+			SetQueryParams(params).
+			Get(t.config.HelixURL + "/" + endpoint)
+
+		if err != nil {
+			return fmt.Errorf("failed to call helix %s: %w", endpoint, err)
+		}
+		if resp.StatusCode() != http.StatusUnauthorized {
+			break
+		}
+		t.logger.Debug("App access token rejected, requesting a new one")
+	}
+
+	var data struct {
+		Data    json.RawMessage `json:"data"`
+		Message string          `json:"message"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("helix API error (status %d): %s", resp.StatusCode(), data.Message)
+	}
+
+	if err := json.Unmarshal(data.Data, out); err != nil {
+		return fmt.Errorf("failed to parse %s data: %w", endpoint, err)
+	}
+	return nil
+}
+
+// GetTwitchStream returns the channel's live stream, or nil if it is offline
+func (t *TwitchService) GetTwitchStream() (*TwitchStream, error) {
+	var streams []TwitchStream
+	if err := t.helixGet("streams", map[string]string{"user_login": t.Login}, &streams); err != nil {
+		return nil, err
+	}
+
+	for _, stream := range streams {
+		if stream.Type == "live" {
+			return &stream, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetTwitchUser returns the channel's user profile
+func (t *TwitchService) GetTwitchUser() (*TwitchUser, error) {
+	var users []TwitchUser
+	if err := t.helixGet("users", map[string]string{"login": t.Login}, &users); err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("user %s does not exist", t.Login)
+	}
+	return &users[0], nil
+}
+
+// GetTwitchLiveStatus retrieves the live status of the channel
+func (t *TwitchService) GetTwitchLiveStatus() (bool, error) {
+	stream, err := t.GetTwitchStream()
+	if err != nil {
+		return false, fmt.Errorf("failed to get live status: %w", err)
+	}
+
+	isLive := stream != nil
+	status := "offline"
+	if isLive {
+		status = "live"
+	}
+	t.logger.WithFields(logrus.Fields{
+		"room_id": t.Login,
+		"status":  status,
+	}).Info("Room status check completed")
+	return isLive, nil
+}
+
+// GetTwitchLiveRealURL resolves the channel's HLS master playlist URL
+// through a GQL playback access token and the usher service
+func (t *TwitchService) GetTwitchLiveRealURL() (string, error) {
+	body := map[string]interface{}{
+		"operationName": "PlaybackAccessToken_Template",
+		"query":         twitchPlaybackQuery,
+		"variables": map[string]interface{}{
+			"isLive":     true,
+			"login":      t.Login,
+			"isVod":      false,
+			"vodID":      "",
+			"playerType": "site",
+		},
+	}
+
+	resp, err := t.Client.R().
+		SetHeader("Client-ID", twitchWebClientID).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(t.config.GQLURL)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get playback token: %w", err)
+	}
+
+	var data struct {
+		Data struct {
+			StreamPlaybackAccessToken *struct {
+				Value     string `json:"value"`
+				Signature string `json:"signature"`
+			} `json:"streamPlaybackAccessToken"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return "", fmt.Errorf("failed to parse playback token response: %w", err)
+	}
+
+	if len(data.Errors) > 0 {
+		return "", fmt.Errorf("playback token API error: %s", data.Errors[0].Message)
+	}
+
+	token := data.Data.StreamPlaybackAccessToken
+	if token == nil || token.Value == "" {
+		return "", fmt.Errorf("no playback token for channel %s", t.Login)
+	}
+
+	query := url.Values{}
+	query.Set("sig", token.Signature)
+	query.Set("token", token.Value)
+	query.Set("allow_source", "true")
+	query.Set("allow_audio_only", "true")
+	query.Set("fast_bread", "true")
+	query.Set("player", "twitchweb")
+	query.Set("p", strconv.Itoa(rand.Intn(1000000)))
+	playlistURL := fmt.Sprintf("%s/api/channel/hls/%s.m3u8?%s", t.config.UsherURL, t.Login, query.Encode())
+
+	// Usher answers 404 for offline channels
+	resp, err = t.Client.R().Get(playlistURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to get playlist: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK || !strings.HasPrefix(string(resp.Body()), "#EXTM3U") {
+		return "", fmt.Errorf("no live playlist for channel %s (status %d)", t.Login, resp.StatusCode())
+	}
+
+	return playlistURL, nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// twitchStandIn fakes the Twitch auth, Helix, GQL and usher endpoints
+type twitchStandIn struct {
+	server      *httptest.Server
+	live        atomic.Bool
+	tokens      atomic.Int32
+	rejectToken atomic.Bool
+}
+
+func newTwitchStandIn(t *testing.T) *twitchStandIn {
+	t.Helper()
+
+	s := &twitchStandIn{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":400,"message":"invalid client secret"}`))
+			return
+		}
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		n := s.tokens.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token%d","expires_in":5000000,"token_type":"bearer"}`, n)
+	})
+
+	helixAuth := func(w http.ResponseWriter, r *http.Request) bool {
+		assert.Equal(t, "client", r.Header.Get("Client-Id"))
+		if s.rejectToken.Load() && r.Header.Get("Authorization") == "Bearer token1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Unauthorized","status":401,"message":"Invalid OAuth token"}`))
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("/helix/streams", func(w http.ResponseWriter, r *http.Request) {
+		if !helixAuth(w, r) {
+			return
+		}
+		assert.Equal(t, "somechannel", r.URL.Query().Get("user_login"))
+		if !s.live.Load() {
+			_, _ = w.Write([]byte(`{"data":[],"pagination":{}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"4001","user_id":"1001","user_login":"somechannel","user_name":"SomeChannel","game_name":"Just Chatting","type":"live","title":"Hello Twitch","viewer_count":42,"started_at":"2024-03-01T12:00:00Z","thumbnail_url":"https://static-cdn.jtvnw.net/previews-ttv/live_user_somechannel-{width}x{height}.jpg"}],"pagination":{}}`))
+	})
+
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		if !helixAuth(w, r) {
+			return
+		}
+		if r.URL.Query().Get("login") != "somechannel" {
+			_, _ = w.Write([]byte(`{"data":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"1001","login":"somechannel","display_name":"SomeChannel","profile_image_url":"https://static-cdn.jtvnw.net/profile.png","offline_image_url":"https://static-cdn.jtvnw.net/offline.png"}]}`))
+	})
+
+	mux.HandleFunc("/gql", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, twitchWebClientID, r.Header.Get("Client-ID"))
+		var body struct {
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "PlaybackAccessToken_Template", body.OperationName)
+		assert.Equal(t, "somechannel", body.Variables["login"])
+		_, _ = w.Write([]byte(`{"data":{"streamPlaybackAccessToken":{"value":"{\"channel\":\"somechannel\"}","signature":"abc123","__typename":"PlaybackAccessToken"}}}`))
+	})
+
+	mux.HandleFunc("/api/channel/hls/somechannel.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc123", r.URL.Query().Get("sig"))
+		assert.Equal(t, `{"channel":"somechannel"}`, r.URL.Query().Get("token"))
+		if !s.live.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`[{"error":"twirp error not_found: transcode does not exist"}]`))
+			return
+		}
+		_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=6000000\nhttps://video.example.com/source.m3u8\n"))
+	})
+
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *twitchStandIn) config() TwitchConfig {
+	return TwitchConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		HelixURL:     s.server.URL + "/helix",
+		AuthURL:      s.server.URL,
+		GQLURL:       s.server.URL + "/gql",
+		UsherURL:     s.server.URL,
+	}
+}
+
+func newTestTwitchService(t *testing.T, s *twitchStandIn) *TwitchService {
+	t.Helper()
+	svc, err := NewTwitchService("SomeChannel", s.config())
+	require.NoError(t, err)
+	svc.Client.SetRetryCount(0)
+	return svc
+}
+
+func TestNewTwitchService(t *testing.T) {
+	svc, err := NewTwitchService("somechannel", TwitchConfig{ClientID: "id", ClientSecret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, twitchHelixURL, svc.config.HelixURL)
+	assert.Equal(t, twitchAuthURL, svc.config.AuthURL)
+	assert.Equal(t, twitchGQLURL, svc.config.GQLURL)
+	assert.Equal(t, twitchUsherURL, svc.config.UsherURL)
+
+	_, err = NewTwitchService("somechannel", TwitchConfig{})
+	assert.ErrorContains(t, err, "client_id")
+
+	_, err = NewTwitchService("bad login", TwitchConfig{ClientID: "id", ClientSecret: "secret"})
+	assert.Error(t, err)
+}
+
+func TestTwitchService_Status(t *testing.T) {
+	s := newTwitchStandIn(t)
+	svc := newTestTwitchService(t, s)
+
+	isLive, err := svc.GetTwitchLiveStatus()
+	require.NoError(t, err)
+	assert.False(t, isLive)
+
+	s.live.Store(true)
+	stream, err := svc.GetTwitchStream()
+	require.NoError(t, err)
+	require.NotNil(t, stream)
+	assert.Equal(t, "Hello Twitch", stream.Title)
+	assert.Equal(t, "1001", stream.UserID)
+	assert.Equal(t, "SomeChannel", stream.UserName)
+	assert.Equal(t, 2024, stream.StartedAt.Year())
+	assert.Contains(t, stream.ThumbnailURL, "{width}x{height}")
+
+	user, err := svc.GetTwitchUser()
+	require.NoError(t, err)
+	assert.Equal(t, "SomeChannel", user.DisplayName)
+
+	// The app access token is requested once and reused
+	assert.Equal(t, int32(1), s.tokens.Load())
+}
+
+func TestTwitchService_TokenRefresh(t *testing.T) {
+	s := newTwitchStandIn(t)
+	svc := newTestTwitchService(t, s)
+
+	_, err := svc.GetTwitchLiveStatus()
+	require.NoError(t, err)
+
+	// A revoked token is replaced transparently
+	s.rejectToken.Store(true)
+	_, err = svc.GetTwitchLiveStatus()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), s.tokens.Load())
+}
+
+func TestTwitchService_BadCredentials(t *testing.T) {
+	s := newTwitchStandIn(t)
+	config := s.config()
+	config.ClientSecret = "wrong"
+	svc, err := NewTwitchService("somechannel", config)
+	require.NoError(t, err)
+	svc.Client.SetRetryCount(0)
+
+	_, err = svc.GetTwitchLiveStatus()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid client secret")
+}
+
+func TestTwitchService_GetTwitchLiveRealURL(t *testing.T) {
+	s := newTwitchStandIn(t)
+	svc := newTestTwitchService(t, s)
+
+	_, err := svc.GetTwitchLiveRealURL()
+	assert.ErrorContains(t, err, "status 404")
+
+	s.live.Store(true)
+	playURL, err := svc.GetTwitchLiveRealURL()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(playURL, s.server.URL+"/api/channel/hls/somechannel.m3u8?"), playURL)
+	assert.Contains(t, playURL, "sig=abc123")
+}
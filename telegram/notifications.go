@@ -26,6 +26,36 @@ func escapeMarkdown(text string) string {
 	return escaped
 }
 
+// liveRoomURL returns the link to a room on its platform, or "" if it has no page
+func liveRoomURL(platform, roomID string) string {
+	switch platform {
+	case "url":
+		// Raw stream URLs often carry stream keys and are never linked
+		return ""
+	case "douyu":
+		return fmt.Sprintf("https://www.douyu.com/%s", roomID)
+	case "huya":
+		return fmt.Sprintf("https://www.huya.com/%s", roomID)
+	case "twitch":
+		return fmt.Sprintf("https://www.twitch.tv/%s", roomID)
+	default:
+		return fmt.Sprintf("https://live.bilibili.com/%s", roomID)
+	}
+}
+
+// anchorSpaceURL returns the link to the anchor's profile, or "" if unknown
+func anchorSpaceURL(roomInfo models.RoomInfo) string {
+	if roomInfo.UID == "" {
+		return ""
+	}
+	switch roomInfo.Platform {
+	case "", "bilibili":
+		return fmt.Sprintf("https://space.bilibili.com/%s", roomInfo.UID)
+	default:
+		return ""
+	}
+}
+
 // FormatLiveStartNotification formats a notification for when a live stream starts
 // Returns the text message and photo URL
 func FormatLiveStartNotification(roomInfo models.RoomInfo) (string, string) {
@@ -36,7 +66,7 @@ func FormatLiveStartNotification(roomInfo models.RoomInfo) (string, string) {
 	}
 
 	// Format the live stream URL
-	liveURL := fmt.Sprintf("https://live.bilibili.com/%s", roomID)
+	liveURL := liveRoomURL(roomInfo.Platform, roomID)
 
 	// Format the start time
 	timeStr := ""
@@ -67,7 +97,9 @@ func FormatLiveStartNotification(roomInfo models.RoomInfo) (string, string) {
 	message += fmt.Sprintf("⏰ 开播时间：_%s_\n\n", timeStr)
 
 	// Live room link
-	message += fmt.Sprintf("[👉 进入直播间](%s)", liveURL)
+	if liveURL != "" {
+		message += fmt.Sprintf("[👉 进入直播间](%s)", liveURL)
+	}
 
 	// Determine which image to use (prefer user_cover, fall back to keyframe)
 	photoURL := roomInfo.UserCover
@@ -98,10 +130,7 @@ func FormatLiveEndNotification(roomInfo models.RoomInfo) string {
 	}
 
 	// Anchor's space URL
-	spaceURL := ""
-	if roomInfo.UID != "" {
-		spaceURL = fmt.Sprintf("https://space.bilibili.com/%s", roomInfo.UID)
-	}
+	spaceURL := anchorSpaceURL(roomInfo)
 
 	// Build the message
 	message := fmt.Sprintf("💤 *%s* 已经下播了\n\n", escapedUName)
@@ -115,7 +144,66 @@ func FormatLiveEndNotification(roomInfo models.RoomInfo) string {
 	}
 
 	// Add live room link
-	message += fmt.Sprintf("[🎬 直播间回放](https://live.bilibili.com/%s)", roomID)
+	if liveURL := liveRoomURL(roomInfo.Platform, roomID); liveURL != "" {
+		message += fmt.Sprintf("[🎬 直播间回放](%s)", liveURL)
+	}
+
+	return message
+}
+
+// FormatRotationNotification formats a notification for when a room starts
+// or stops replaying recorded videos (轮播)
+func FormatRotationNotification(roomInfo models.RoomInfo, rotating bool) string {
+	roomID := roomInfo.RealRoomID
+	if roomID == "" {
+		roomID = roomInfo.RoomID
+	}
+
+	escapedUName := escapeMarkdown(roomInfo.UName)
+	timeStr := time.Now().Format("2006-01-02 15:04:05")
+
+	var message string
+	if rotating {
+		message = fmt.Sprintf("🔁 *%s* 的直播间开始轮播\n\n", escapedUName)
+	} else {
+		message = fmt.Sprintf("⏹ *%s* 的直播间轮播结束\n\n", escapedUName)
+	}
+	message += fmt.Sprintf("⏰ 时间：_%s_\n\n", timeStr)
+
+	if liveURL := liveRoomURL(roomInfo.Platform, roomID); liveURL != "" {
+		message += fmt.Sprintf("[👉 进入直播间](%s)", liveURL)
+	}
+
+	return message
+}
+
+// FormatCatchUpSummary formats the status changes that happened while the
+// monitor was not running, sent instead of one notification per room
+func FormatCatchUpSummary(changes []models.StatusChange) string {
+	labels := map[models.LiveStatus]string{
+		models.StatusOffline:  "未开播",
+		models.StatusLive:     "直播中",
+		models.StatusRotation: "轮播中",
+	}
+
+	message := fmt.Sprintf("📋 *监控恢复*\n\n停止期间有 %d 个直播间状态发生变化：\n", len(changes))
+	for _, change := range changes {
+		roomID := change.RoomInfo.RealRoomID
+		if roomID == "" {
+			roomID = change.RoomInfo.RoomID
+		}
+
+		name := escapeMarkdown(change.RoomInfo.UName)
+		if name == "" {
+			name = escapeMarkdown(roomID)
+		}
+		message += fmt.Sprintf("\n• *%s*：%s → %s", name, labels[change.From], labels[change.To])
+		if change.To == models.StatusLive {
+			if liveURL := liveRoomURL(change.RoomInfo.Platform, roomID); liveURL != "" {
+				message += fmt.Sprintf(" [进入直播间](%s)", liveURL)
+			}
+		}
+	}
 
 	return message
 }
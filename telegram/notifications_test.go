@@ -207,3 +207,111 @@ func TestFormatStatusNotification_WithSpecialChars(t *testing.T) {
 
 	t.Logf("Formatted message with special chars:\n%s", message)
 }
+
+func TestNotificationLinksByPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		roomInfo  models.RoomInfo
+		wantLink  string
+		wantSpace string
+	}{
+		{
+			"bilibili",
+			models.RoomInfo{Platform: "bilibili", RoomID: "123", RealRoomID: "456", UID: "789", UName: "主播"},
+			"https://live.bilibili.com/456",
+			"https://space.bilibili.com/789",
+		},
+		{
+			"douyu",
+			models.RoomInfo{Platform: "douyu", RoomID: "qiezi", RealRoomID: "288016", UName: "主播"},
+			"https://www.douyu.com/288016",
+			"",
+		},
+		{
+			"huya",
+			models.RoomInfo{Platform: "huya", RoomID: "660000", RealRoomID: "660000", UID: "1199512345678", UName: "主播"},
+			"https://www.huya.com/660000",
+			"",
+		},
+		{
+			"twitch",
+			models.RoomInfo{Platform: "twitch", RoomID: "somechannel", UID: "1001", UName: "SomeChannel"},
+			"https://www.twitch.tv/somechannel",
+			"",
+		},
+		{
+			"url",
+			models.RoomInfo{Platform: "url", RoomID: "srs-main", UName: "SRS"},
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, _ := FormatLiveStartNotification(tt.roomInfo)
+			if tt.wantLink == "" {
+				if strings.Contains(start, "](") {
+					t.Errorf("Start message should not contain a link, got:\n%s", start)
+				}
+				if end := FormatLiveEndNotification(tt.roomInfo); strings.Contains(end, "](") {
+					t.Errorf("End message should not contain a link, got:\n%s", end)
+				}
+				return
+			}
+			if !strings.Contains(start, tt.wantLink) {
+				t.Errorf("Start message should contain %s, got:\n%s", tt.wantLink, start)
+			}
+
+			end := FormatLiveEndNotification(tt.roomInfo)
+			if !strings.Contains(end, tt.wantLink) {
+				t.Errorf("End message should contain %s, got:\n%s", tt.wantLink, end)
+			}
+			if tt.wantSpace != "" && !strings.Contains(end, tt.wantSpace) {
+				t.Errorf("End message should contain %s, got:\n%s", tt.wantSpace, end)
+			}
+			if tt.wantSpace == "" && strings.Contains(end, "space.bilibili.com") {
+				t.Errorf("End message should not link a Bilibili space, got:\n%s", end)
+			}
+		})
+	}
+}
+
+func TestFormatRotationNotification(t *testing.T) {
+	roomInfo := models.RoomInfo{
+		Platform:   "bilibili",
+		RoomID:     "123",
+		RealRoomID: "456",
+		UName:      "Test主播",
+	}
+
+	start := FormatRotationNotification(roomInfo, true)
+	if !strings.Contains(start, "Test主播") || !strings.Contains(start, "开始轮播") {
+		t.Errorf("Unexpected rotation start message: %s", start)
+	}
+	if !strings.Contains(start, "https://live.bilibili.com/456") {
+		t.Errorf("Rotation start message should link the real room: %s", start)
+	}
+
+	end := FormatRotationNotification(roomInfo, false)
+	if !strings.Contains(end, "轮播结束") {
+		t.Errorf("Unexpected rotation end message: %s", end)
+	}
+}
+
+func TestFormatCatchUpSummary(t *testing.T) {
+	message := FormatCatchUpSummary([]models.StatusChange{
+		{RoomInfo: models.RoomInfo{Platform: "bilibili", RoomID: "123", RealRoomID: "456", UName: "Test主播"}, From: models.StatusOffline, To: models.StatusLive},
+		{RoomInfo: models.RoomInfo{Platform: "bilibili", RoomID: "789"}, From: models.StatusLive, To: models.StatusOffline},
+	})
+
+	if !strings.Contains(message, "2 个直播间") {
+		t.Errorf("Summary should count the changes: %s", message)
+	}
+	if !strings.Contains(message, "*Test主播*：未开播 → 直播中 [进入直播间](https://live.bilibili.com/456)") {
+		t.Errorf("Summary should link rooms that went live: %s", message)
+	}
+	if !strings.Contains(message, "*789*：直播中 → 未开播") || strings.Contains(message, "live.bilibili.com/789") {
+		t.Errorf("Unexpected line for a room that went offline: %s", message)
+	}
+}